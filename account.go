@@ -0,0 +1,239 @@
+package scaffold
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/alexsobiek/scaffold/query"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const minPasswordLength = 8
+
+type AccountOpts struct {
+	// Collection is the name of the collection users are stored in, defaults to "_users".
+	Collection string
+	// Path is where the account endpoints are mounted, defaults to "/auth".
+	Path string
+	// Hasher hashes new passwords, defaults to bcrypt. Existing bcrypt and argon2id hashes are
+	// always accepted.
+	Hasher auth.PasswordHasher
+	// DisableRegistration removes the /register endpoint, users can still be created with
+	// Accounts.Register.
+	DisableRegistration bool
+	// DefaultRoles are granted to users created through the /register endpoint.
+	DefaultRoles []string
+}
+
+type User struct {
+	Username     string   `bson:"username" json:"username"`
+	PasswordHash string   `bson:"password_hash" json:"-"`
+	Roles        []string `bson:"roles,omitempty" json:"roles,omitempty"`
+}
+
+type credentials struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Accounts manages username/password users stored in a Scaffold-managed collection.
+type Accounts struct {
+	opts     AccountOpts
+	c        *C[User]
+	sessions *sessions
+	dummy    string
+}
+
+func newAccounts(db *Database, opts AccountOpts, sessions *sessions) (*Accounts, error) {
+	if opts.Collection == "" {
+		opts.Collection = "_users"
+	}
+
+	if opts.Path == "" {
+		opts.Path = "/auth"
+	}
+
+	if opts.Hasher == nil {
+		opts.Hasher = auth.BcryptHasher{}
+	}
+
+	c := NewCollection(CollectionOpts[User]{
+		Name: "Users",
+		Slug: opts.Collection,
+	})
+
+	c.mc = db.Collection(opts.Collection)
+
+	_, err := c.mc.Indexes().CreateOne(db.ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "username", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Hash used to keep login timing constant for unknown usernames
+	dummy, err := opts.Hasher.Hash("scaffold-dummy-password")
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Accounts{opts: opts, c: c, sessions: sessions, dummy: dummy}, nil
+}
+
+// Register creates a new user with the given password and roles.
+func (a *Accounts) Register(ctx context.Context, username string, password string, roles ...string) (*Document[User], error) {
+	if username == "" {
+		return nil, http.ErrBadRequest{Message: "username is required"}
+	}
+
+	if len(password) < minPasswordLength {
+		return nil, http.ErrBadRequest{Message: "password is too short"}
+	}
+
+	hash, err := a.opts.Hasher.Hash(password)
+
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := a.c.Insert(ctx, User{
+		Username:     username,
+		PasswordHash: hash,
+		Roles:        roles,
+	})
+
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, http.ErrBadRequest{Message: "username is taken"}
+	}
+
+	return doc, err
+}
+
+// Login verifies a username and password, returning the matching user.
+func (a *Accounts) Login(ctx context.Context, username string, password string) (*Document[User], error) {
+	invalid := http.ErrUnauthorized{Message: "invalid username or password"}
+
+	doc, err := a.c.Find(ctx, &query.Comparison{Operator: query.Equal, Field: "username", Value: username})
+
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+
+		_, _ = auth.VerifyPassword(a.dummy, password)
+		return nil, invalid
+	}
+
+	ok, err := auth.VerifyPassword(doc.Data.PasswordHash, password)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, invalid
+	}
+
+	return doc, nil
+}
+
+// SetPassword replaces the password of a user.
+func (a *Accounts) SetPassword(ctx context.Context, doc *Document[User], password string) error {
+	if len(password) < minPasswordLength {
+		return http.ErrBadRequest{Message: "password is too short"}
+	}
+
+	hash, err := a.opts.Hasher.Hash(password)
+
+	if err != nil {
+		return err
+	}
+
+	return doc.Set(ctx, "password_hash", hash)
+}
+
+func (a *Accounts) inject(rg *gin.RouterGroup) {
+	if !a.opts.DisableRegistration {
+		rg.POST("/register", a.handleRegister)
+	}
+
+	rg.POST("/login", a.handleLogin)
+	rg.POST("/logout", a.handleLogout)
+	rg.GET("/me", a.handleMe)
+}
+
+func (a *Accounts) handleRegister(ctx *gin.Context) {
+	var creds credentials
+
+	if err := ctx.BindJSON(&creds); err != nil {
+		http.BadRequest(ctx, err)
+		return
+	}
+
+	doc, err := a.Register(ctx, creds.Username, creds.Password, a.opts.DefaultRoles...)
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	http.Created(ctx, doc)
+}
+
+func (a *Accounts) handleLogin(ctx *gin.Context) {
+	var creds credentials
+
+	if err := ctx.BindJSON(&creds); err != nil {
+		http.BadRequest(ctx, err)
+		return
+	}
+
+	doc, err := a.Login(ctx, creds.Username, creds.Password)
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	err = a.sessions.start(ctx, &auth.Principal{
+		Subject: doc.ID.Hex(),
+		Method:  "session",
+		Roles:   doc.Data.Roles,
+		Claims:  map[string]any{"username": doc.Data.Username},
+	})
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	http.Ok(ctx, doc)
+}
+
+func (a *Accounts) handleLogout(ctx *gin.Context) {
+	if err := a.sessions.end(ctx); err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	http.Ok(ctx, gin.H{"logged_out": true})
+}
+
+func (a *Accounts) handleMe(ctx *gin.Context) {
+	p, ok := auth.FromContext(ctx)
+
+	if !ok {
+		http.Unauthorized(ctx, errors.New("not logged in"))
+		return
+	}
+
+	http.Ok(ctx, p)
+}
@@ -0,0 +1,154 @@
+package scaffold
+
+import (
+	"context"
+	nethttp "net/http"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestAccounts(t *testing.T) {
+	s, h := testScaffold(t, ScaffoldOpts{
+		Accounts: &AccountOpts{DefaultRoles: []string{"member"}},
+		Sessions: &SessionOpts{CookieSecure: true, TTL: time.Hour},
+	})
+
+	creds := func(username string, password string) map[string]string {
+		return map[string]string{"username": username, "password": password}
+	}
+
+	expectStatus(t, request(h, "POST", "/auth/register", creds("alice", "correct horse")), nethttp.StatusCreated)
+
+	t.Run("registration", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			body   any
+			status int
+		}{
+			{"username taken", creds("alice", "another password"), nethttp.StatusBadRequest},
+			{"password too short", creds("bob", "short"), nethttp.StatusBadRequest},
+			{"missing password", map[string]string{"username": "bob"}, nethttp.StatusBadRequest},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				expectStatus(t, request(h, "POST", "/auth/register", tt.body), tt.status)
+			})
+		}
+	})
+
+	t.Run("password is hashed", func(t *testing.T) {
+		n, err := s.accounts.c.mc.CountDocuments(context.Background(), bson.M{"password_hash": "correct horse"})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 0 {
+			t.Fatal("plaintext password was stored")
+		}
+	})
+
+	t.Run("login failures", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body any
+		}{
+			{"wrong password", creds("alice", "wrong horse")},
+			{"unknown user", creds("mallory", "correct horse")},
+			{"empty password", creds("alice", "")},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := request(h, "POST", "/auth/login", tt.body)
+
+				if w.Code != nethttp.StatusUnauthorized && w.Code != nethttp.StatusBadRequest {
+					t.Fatalf("expected login to fail, got %d", w.Code)
+				}
+
+				if _, ok := cookie(w, "scaffold_session"); ok {
+					t.Fatal("failed login set a session cookie")
+				}
+			})
+		}
+	})
+
+	t.Run("unknown users take as long as wrong passwords", func(t *testing.T) {
+		ctx := context.Background()
+
+		measure := func(username string) time.Duration {
+			start := time.Now()
+
+			if _, err := s.accounts.Login(ctx, username, "wrong horse"); err == nil {
+				t.Fatal("expected login to fail")
+			}
+
+			return time.Since(start)
+		}
+
+		known := measure("alice")
+		unknown := measure("mallory")
+
+		if unknown < known/4 {
+			t.Fatalf("unknown user rejected in %s, wrong password in %s", unknown, known)
+		}
+	})
+
+	t.Run("session", func(t *testing.T) {
+		w := request(h, "POST", "/auth/login", creds("alice", "correct horse"))
+		expectStatus(t, w, nethttp.StatusOK)
+
+		session, ok := cookie(w, "scaffold_session")
+
+		if !ok {
+			t.Fatal("login did not set a session cookie")
+		}
+
+		if !session.HttpOnly || !session.Secure || session.SameSite != nethttp.SameSiteLaxMode || session.Path != "/" || session.MaxAge != 3600 {
+			t.Fatalf("unexpected session cookie %+v", session)
+		}
+
+		n, err := s.sessions.c.mc.CountDocuments(context.Background(), bson.M{"token_hash": session.Value})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n != 0 {
+			t.Fatal("plaintext session token was stored")
+		}
+
+		me := request(h, "GET", "/auth/me", nil, "Cookie", session.String())
+		expectStatus(t, me, nethttp.StatusOK)
+
+		expectStatus(t, request(h, "GET", "/auth/me", nil, "Cookie", "scaffold_session=forged"), nethttp.StatusUnauthorized)
+
+		w = request(h, "POST", "/auth/logout", nil, "Cookie", session.String())
+		expectStatus(t, w, nethttp.StatusOK)
+
+		if cleared, ok := cookie(w, "scaffold_session"); !ok || cleared.MaxAge >= 0 {
+			t.Fatal("logout did not clear the session cookie")
+		}
+
+		expectStatus(t, request(h, "GET", "/auth/me", nil, "Cookie", session.String()), nethttp.StatusUnauthorized)
+	})
+
+	t.Run("expired session", func(t *testing.T) {
+		w := request(h, "POST", "/auth/login", creds("alice", "correct horse"))
+		expectStatus(t, w, nethttp.StatusOK)
+
+		session, _ := cookie(w, "scaffold_session")
+
+		_, err := s.sessions.c.mc.UpdateOne(context.Background(), bson.M{"token_hash": hashToken(session.Value)},
+			bson.M{"$set": bson.M{"expires": time.Now().Add(-time.Minute)}})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectStatus(t, request(h, "GET", "/auth/me", nil, "Cookie", session.String()), nethttp.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,360 @@
+package scaffold
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/alexsobiek/scaffold/query"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const apiKeyPrefix = "sk_"
+
+type APIKeyOpts struct {
+	// Collection is the name of the collection keys are stored in, defaults to "_api_keys".
+	Collection string
+	// AdminPath is where the key management endpoints are mounted, defaults to "/_admin/api-keys".
+	AdminPath string
+	// AdminRole is the role required to manage keys, defaults to "admin".
+	AdminRole string
+	// Header is the request header carrying the key, defaults to "X-API-Key". Keys are also
+	// accepted as "Authorization: ApiKey <key>".
+	Header string
+}
+
+// APIKey is a stored API key. Only a SHA-256 hash of the key is persisted.
+type APIKey struct {
+	Name    string             `bson:"name" json:"name"`
+	Prefix  string             `bson:"prefix" json:"prefix"`
+	Hash    string             `bson:"hash" json:"-"`
+	Subject string             `bson:"subject" json:"subject"`
+	Roles   []string           `bson:"roles,omitempty" json:"roles,omitempty"`
+	Scopes  []auth.Scope       `bson:"scopes" json:"scopes"`
+	Expires primitive.DateTime `bson:"expires,omitempty" json:"expires,omitempty"`
+	Revoked bool               `bson:"revoked" json:"revoked"`
+}
+
+// NewAPIKey describes a key to be created.
+type NewAPIKey struct {
+	Name    string       `json:"name"`
+	Subject string       `json:"subject"`
+	Roles   []string     `json:"roles"`
+	Scopes  []auth.Scope `json:"scopes"`
+	Expires time.Time    `json:"expires"`
+}
+
+// APIKeys manages API keys stored in a Scaffold-managed collection.
+type APIKeys struct {
+	opts APIKeyOpts
+	c    *C[APIKey]
+}
+
+func newAPIKeys(db *Database, opts APIKeyOpts) (*APIKeys, error) {
+	if opts.Collection == "" {
+		opts.Collection = "_api_keys"
+	}
+
+	if opts.AdminPath == "" {
+		opts.AdminPath = "/_admin/api-keys"
+	}
+
+	if opts.AdminRole == "" {
+		opts.AdminRole = "admin"
+	}
+
+	if opts.Header == "" {
+		opts.Header = "X-API-Key"
+	}
+
+	c := NewCollection(CollectionOpts[APIKey]{
+		Name: "API Keys",
+		Slug: opts.Collection,
+	})
+
+	c.mc = db.Collection(opts.Collection)
+
+	_, err := c.mc.Indexes().CreateOne(db.ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIKeys{opts: opts, c: c}, nil
+}
+
+// Create stores a new key and returns it along with the plaintext key, which cannot be
+// recovered later.
+func (k *APIKeys) Create(ctx context.Context, req NewAPIKey) (*Document[APIKey], string, error) {
+	if len(req.Scopes) == 0 {
+		return nil, "", http.ErrBadRequest{Message: "at least one scope is required"}
+	}
+
+	prefix, key, err := generateAPIKey()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	data := APIKey{
+		Name:    req.Name,
+		Prefix:  prefix,
+		Hash:    hashToken(key),
+		Subject: req.Subject,
+		Roles:   req.Roles,
+		Scopes:  req.Scopes,
+	}
+
+	if !req.Expires.IsZero() {
+		data.Expires = primitive.NewDateTimeFromTime(req.Expires)
+	}
+
+	doc, err := k.c.Insert(ctx, data)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if doc.Data.Subject == "" {
+		err = doc.Set(ctx, "subject", "apikey:"+doc.ID.Hex())
+
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return doc, key, nil
+}
+
+// Rotate replaces the secret of an existing key, invalidating the previous one.
+func (k *APIKeys) Rotate(ctx context.Context, id primitive.ObjectID) (*Document[APIKey], string, error) {
+	doc, err := k.c.FindById(ctx, id)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if doc.Data.Revoked {
+		return nil, "", http.ErrBadRequest{Message: "key has been revoked"}
+	}
+
+	prefix, key, err := generateAPIKey()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = doc.SetMany(ctx, map[string]any{
+		"prefix": prefix,
+		"hash":   hashToken(key),
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return doc, key, nil
+}
+
+// Revoke permanently disables a key.
+func (k *APIKeys) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	doc, err := k.c.FindById(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	return doc.Set(ctx, "revoked", true)
+}
+
+func (k *APIKeys) List(ctx context.Context, limit int, page int) ([]Document[APIKey], error) {
+	return k.c.FindMany(ctx, query.Empty(), limit, page)
+}
+
+func (k *APIKeys) Authenticate(c *gin.Context) (*auth.Principal, error) {
+	key := c.GetHeader(k.opts.Header)
+
+	if key == "" {
+		scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+
+		if ok && strings.EqualFold(scheme, "ApiKey") {
+			key = strings.TrimSpace(token)
+		}
+	}
+
+	if key == "" {
+		return nil, auth.ErrNoCredentials
+	}
+
+	invalid := http.ErrUnauthorized{Message: "invalid api key"}
+
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, invalid
+	}
+
+	doc, err := k.c.Find(c, &query.Comparison{Operator: query.Equal, Field: "hash", Value: hashToken(key)})
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, invalid
+		}
+		return nil, err
+	}
+
+	if doc.Data.Revoked {
+		return nil, invalid
+	}
+
+	if doc.Data.Expires != 0 && doc.Data.Expires.Time().Before(time.Now()) {
+		return nil, http.ErrUnauthorized{Message: "api key expired"}
+	}
+
+	return &auth.Principal{
+		Subject: doc.Data.Subject,
+		Method:  "api_key",
+		Roles:   doc.Data.Roles,
+		Scopes:  doc.Data.Scopes,
+		Claims:  map[string]any{"key_id": doc.ID.Hex()},
+	}, nil
+}
+
+func (k *APIKeys) inject(rg *gin.RouterGroup) {
+	rg.Use(auth.RequireRole(k.opts.AdminRole))
+
+	rg.GET("/", k.handleList)
+	rg.POST("/", k.handleCreate)
+	rg.POST("/:id/rotate", k.handleRotate)
+	rg.DELETE("/:id", k.handleRevoke)
+}
+
+type apiKeyResponse struct {
+	Key    string            `json:"key"`
+	APIKey *Document[APIKey] `json:"api_key"`
+}
+
+func (k *APIKeys) handleList(ctx *gin.Context) {
+	var err error
+	limit := 10
+	page := 1
+
+	if ctx.Query("limit") != "" {
+		limit, err = strconv.Atoi(ctx.Query("limit"))
+
+		if err != nil {
+			http.BadRequest(ctx, err)
+			return
+		}
+	}
+
+	if ctx.Query("page") != "" {
+		page, err = strconv.Atoi(ctx.Query("page"))
+
+		if err != nil || page < 1 {
+			http.BadRequest(ctx, errors.New("page must be greater than 0"))
+			return
+		}
+	}
+
+	keys, err := k.List(ctx, limit, page)
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	http.Paginated(ctx, page, keys)
+}
+
+func (k *APIKeys) handleCreate(ctx *gin.Context) {
+	var req NewAPIKey
+
+	if err := ctx.BindJSON(&req); err != nil {
+		http.BadRequest(ctx, err)
+		return
+	}
+
+	doc, key, err := k.Create(ctx, req)
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	http.Created(ctx, apiKeyResponse{Key: key, APIKey: doc})
+}
+
+func (k *APIKeys) handleRotate(ctx *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+
+	if err != nil {
+		http.BadRequest(ctx, errors.New("invalid id"))
+		return
+	}
+
+	doc, key, err := k.Rotate(ctx, id)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.NotFound(ctx, nil)
+		} else {
+			http.Error(ctx, err)
+		}
+		return
+	}
+
+	http.Ok(ctx, apiKeyResponse{Key: key, APIKey: doc})
+}
+
+func (k *APIKeys) handleRevoke(ctx *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+
+	if err != nil {
+		http.BadRequest(ctx, errors.New("invalid id"))
+		return
+	}
+
+	if err := k.Revoke(ctx, id); err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.NotFound(ctx, nil)
+		} else {
+			http.Error(ctx, err)
+		}
+		return
+	}
+
+	http.Ok(ctx, gin.H{"revoked": true})
+}
+
+// generateAPIKey returns a random key in the form sk_<prefix>_<secret> along with its prefix.
+func generateAPIKey() (string, string, error) {
+	b := make([]byte, 36)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	prefix := hex.EncodeToString(b[:4])
+
+	return prefix, apiKeyPrefix + prefix + "_" + base64.RawURLEncoding.EncodeToString(b[4:]), nil
+}
+
+// hashToken hashes a high entropy secret for storage and lookup.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
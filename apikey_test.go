@@ -0,0 +1,160 @@
+package scaffold
+
+import (
+	"context"
+	nethttp "net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type note struct {
+	Text string `bson:"text" json:"text"`
+}
+
+func TestAPIKeys(t *testing.T) {
+	notes := NewCollection(CollectionOpts[note]{Name: "Notes", Slug: "notes"})
+	s, h := testScaffold(t, ScaffoldOpts{
+		Collections: []Collection{notes},
+		APIKeys:     &APIKeyOpts{},
+	})
+
+	ctx := context.Background()
+
+	if _, err := notes.Insert(ctx, note{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	newKey := func(req NewAPIKey) string {
+		t.Helper()
+
+		if req.Scopes == nil {
+			req.Scopes = []auth.Scope{{Collection: "notes", Operations: []auth.Operation{auth.OpRead, auth.OpList}}}
+		}
+
+		_, key, err := s.keys.Create(ctx, req)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return key
+	}
+
+	reader := newKey(NewAPIKey{Name: "reader"})
+	writer := newKey(NewAPIKey{Name: "writer", Scopes: []auth.Scope{{Collection: "*", Operations: []auth.Operation{auth.OpAll}}}})
+	others := newKey(NewAPIKey{Name: "others", Scopes: []auth.Scope{{Collection: "tasks", Operations: []auth.Operation{auth.OpAll}}}})
+	expired := newKey(NewAPIKey{Name: "expired", Expires: time.Now().Add(-time.Minute)})
+
+	revokedDoc, revoked, err := s.keys.Create(ctx, NewAPIKey{Name: "revoked", Scopes: []auth.Scope{{Collection: "*", Operations: []auth.Operation{auth.OpAll}}}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.keys.Revoke(ctx, revokedDoc.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	rotatedDoc, rotated, err := s.keys.Create(ctx, NewAPIKey{Name: "rotated", Scopes: []auth.Scope{{Collection: "*", Operations: []auth.Operation{auth.OpAll}}}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, replacement, err := s.keys.Rotate(ctx, rotatedDoc.ID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		headers []string
+		status  int
+	}{
+		{"header", "GET", []string{"X-API-Key", reader}, nethttp.StatusOK},
+		{"authorization scheme", "GET", []string{"Authorization", "ApiKey " + reader}, nethttp.StatusOK},
+		{"unknown key", "GET", []string{"X-API-Key", reader + "x"}, nethttp.StatusUnauthorized},
+		{"missing prefix", "GET", []string{"X-API-Key", strings.TrimPrefix(reader, apiKeyPrefix)}, nethttp.StatusUnauthorized},
+		{"expired", "GET", []string{"X-API-Key", expired}, nethttp.StatusUnauthorized},
+		{"revoked", "GET", []string{"X-API-Key", revoked}, nethttp.StatusUnauthorized},
+		{"rotated away", "GET", []string{"X-API-Key", rotated}, nethttp.StatusUnauthorized},
+		{"rotated", "GET", []string{"X-API-Key", replacement}, nethttp.StatusOK},
+		{"operation outside scope", "POST", []string{"X-API-Key", reader}, nethttp.StatusForbidden},
+		{"collection outside scope", "GET", []string{"X-API-Key", others}, nethttp.StatusForbidden},
+		{"wildcard scope", "POST", []string{"X-API-Key", writer}, nethttp.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body any
+
+			if tt.method == "POST" {
+				body = note{Text: "new"}
+			}
+
+			expectStatus(t, request(h, tt.method, "/notes/", body, tt.headers...), tt.status)
+		})
+	}
+
+	t.Run("only hashes are stored", func(t *testing.T) {
+		for _, key := range []string{reader, writer, replacement} {
+			n, err := s.keys.c.mc.CountDocuments(ctx, bson.M{"hash": hashToken(key)})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n != 1 {
+				t.Fatalf("expected one key with the hash of %s, found %d", key, n)
+			}
+
+			n, err = s.keys.c.mc.CountDocuments(ctx, bson.M{"$or": []bson.M{{"hash": key}, {"prefix": key}}})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if n != 0 {
+				t.Fatal("plaintext key was stored")
+			}
+		}
+	})
+
+	t.Run("scopes are required", func(t *testing.T) {
+		if _, _, err := s.keys.Create(ctx, NewAPIKey{Name: "unscoped", Scopes: []auth.Scope{}}); err == nil {
+			t.Fatal("expected an error creating a key without scopes")
+		}
+	})
+}
+
+func TestGenerateAPIKey(t *testing.T) {
+	seen := map[string]bool{}
+
+	for i := 0; i < 100; i++ {
+		prefix, key, err := generateAPIKey()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(key, apiKeyPrefix+prefix+"_") {
+			t.Fatalf("key %s does not start with its prefix %s", key, prefix)
+		}
+
+		if len(key) < 50 || seen[key] {
+			t.Fatalf("weak or repeated key %s", key)
+		}
+
+		seen[key] = true
+
+		if hashToken(key) == hashToken(key+"x") || len(hashToken(key)) != 64 {
+			t.Fatal("unexpected key hash")
+		}
+	}
+}
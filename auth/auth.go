@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNoCredentials is returned by an Authenticator when the request carries no credentials it
+// understands, allowing the next authenticator to be tried.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Authenticator resolves the principal making a request.
+type Authenticator interface {
+	Authenticate(*gin.Context) (*Principal, error)
+}
+
+// AuthenticatorFunc adapts a function to the Authenticator interface.
+type AuthenticatorFunc func(*gin.Context) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(c *gin.Context) (*Principal, error) {
+	return f(c)
+}
+
+// Middleware tries each authenticator in order and attaches the first resolved principal to the
+// request. Requests without credentials pass through anonymously, requests with invalid
+// credentials are rejected with 401.
+func Middleware(authenticators ...Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, a := range authenticators {
+			p, err := a.Authenticate(c)
+
+			if errors.Is(err, ErrNoCredentials) {
+				continue
+			}
+
+			if err != nil {
+				unauthorized(c, err)
+				return
+			}
+
+			Set(c, p)
+			break
+		}
+
+		c.Next()
+	}
+}
+
+// Require rejects requests which have no principal attached.
+func Require() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := FromContext(c); !ok {
+			unauthorized(c, nil)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func unauthorized(c *gin.Context, err error) {
+	var e http.ErrUnauthorized
+
+	if !errors.As(err, &e) {
+		err = nil
+	}
+
+	http.Unauthorized(c, err)
+	c.Abort()
+}
+
+// RequireRole rejects requests whose principal does not hold the given role.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := FromContext(c)
+
+		if !ok {
+			unauthorized(c, nil)
+			return
+		}
+
+		if !p.HasRole(role) {
+			http.Forbidden(c, nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type JWTOpts struct {
+	// Secret is the shared key used to verify HS256 tokens.
+	Secret []byte
+	// PublicKeys are used to verify RS256 (*rsa.PublicKey), ES256 (*ecdsa.PublicKey) and EdDSA
+	// (ed25519.PublicKey) tokens.
+	PublicKeys []crypto.PublicKey
+	// JWKSFile is the path to a local JWKS document. Keys are matched by the token "kid" header.
+	JWKSFile string
+	Issuer   string
+	Audience string
+	Leeway   time.Duration
+	// AllowMissingExpiry accepts tokens without an "exp" claim, which are otherwise rejected as
+	// they would be valid forever.
+	AllowMissingExpiry bool
+	// RolesClaim is the claim holding the principal's roles, defaults to "roles".
+	RolesClaim string
+}
+
+// JWTVerifier authenticates requests carrying an "Authorization: Bearer" JWT.
+type JWTVerifier struct {
+	opts   JWTOpts
+	hmac   [][]byte
+	rsa    []*rsa.PublicKey
+	ec     []*ecdsa.PublicKey
+	ed     []ed25519.PublicKey
+	byKid  map[string]crypto.PublicKey
+	parser *jwt.Parser
+}
+
+func NewJWTVerifier(opts JWTOpts) (*JWTVerifier, error) {
+	if opts.RolesClaim == "" {
+		opts.RolesClaim = "roles"
+	}
+
+	v := &JWTVerifier{
+		opts:  opts,
+		byKid: map[string]crypto.PublicKey{},
+	}
+
+	if len(opts.Secret) > 0 {
+		v.hmac = append(v.hmac, opts.Secret)
+	}
+
+	for _, k := range opts.PublicKeys {
+		if err := v.addKey("", k); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.JWKSFile != "" {
+		set, err := ReadJWKSFile(opts.JWKSFile)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := v.AddJWKS(set); err != nil {
+			return nil, err
+		}
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "RS256", "ES256", "EdDSA"}),
+		jwt.WithLeeway(opts.Leeway),
+	}
+
+	if !opts.AllowMissingExpiry {
+		parserOpts = append(parserOpts, jwt.WithExpirationRequired())
+	}
+
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+
+	v.parser = jwt.NewParser(parserOpts...)
+
+	return v, nil
+}
+
+// AddJWKS registers every key of the given set with the verifier.
+func (v *JWTVerifier) AddJWKS(set *JWKS) error {
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.Key()
+
+		if err != nil {
+			return err
+		}
+
+		if err := v.addKey(k.Kid, key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *JWTVerifier) addKey(kid string, key crypto.PublicKey) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		v.rsa = append(v.rsa, k)
+	case *ecdsa.PublicKey:
+		v.ec = append(v.ec, k)
+	case ed25519.PublicKey:
+		v.ed = append(v.ed, k)
+	case []byte:
+		v.hmac = append(v.hmac, k)
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+
+	if kid != "" {
+		v.byKid[kid] = key
+	}
+
+	return nil
+}
+
+func (v *JWTVerifier) keyFunc(t *jwt.Token) (interface{}, error) {
+	if kid, ok := t.Header["kid"].(string); ok && kid != "" {
+		if key, ok := v.byKid[kid]; ok {
+			return key, nil
+		}
+	}
+
+	var keys []jwt.VerificationKey
+
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		for _, k := range v.hmac {
+			keys = append(keys, k)
+		}
+	case *jwt.SigningMethodRSA:
+		for _, k := range v.rsa {
+			keys = append(keys, k)
+		}
+	case *jwt.SigningMethodECDSA:
+		for _, k := range v.ec {
+			keys = append(keys, k)
+		}
+	case *jwt.SigningMethodEd25519:
+		for _, k := range v.ed {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no key available for token")
+	}
+
+	return jwt.VerificationKeySet{Keys: keys}, nil
+}
+
+// Verify parses and validates a raw token, returning the principal it describes.
+func (v *JWTVerifier) Verify(raw string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+
+	if _, err := v.parser.ParseWithClaims(raw, claims, v.keyFunc); err != nil {
+		return nil, http.ErrUnauthorized{Message: "invalid token"}
+	}
+
+	sub, _ := claims.GetSubject()
+
+	return &Principal{
+		Subject: sub,
+		Method:  "jwt",
+		Roles:   stringsClaim(claims[v.opts.RolesClaim]),
+		Claims:  claims,
+	}, nil
+}
+
+func (v *JWTVerifier) Authenticate(c *gin.Context) (*Principal, error) {
+	raw, ok := BearerToken(c)
+
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+
+	return v.Verify(raw)
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer" header.
+func BearerToken(c *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+
+	return strings.TrimSpace(token), true
+}
+
+// stringsClaim reads a claim which is either a JSON array of strings or a space separated string.
+func stringsClaim(v any) []string {
+	switch val := v.(type) {
+	case string:
+		return strings.Fields(val)
+	case []string:
+		return val
+	case []any:
+		var out []string
+
+		for _, s := range val {
+			if str, ok := s.(string); ok {
+				out = append(out, str)
+			}
+		}
+
+		return out
+	}
+
+	return nil
+}
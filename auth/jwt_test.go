@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	scaffoldhttp "github.com/alexsobiek/scaffold/http"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var secret = []byte("0123456789abcdef0123456789abcdef")
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	tok := jwt.NewWithClaims(method, claims)
+
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+
+	raw, err := tok.SignedString(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+func claims(extra jwt.MapClaims) jwt.MapClaims {
+	c := jwt.MapClaims{
+		"sub": "alice",
+		"iss": "https://issuer.test",
+		"aud": "scaffold",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	// Claims set to nil are removed
+	for k, v := range extra {
+		if v == nil {
+			delete(c, k)
+		} else {
+			c[k] = v
+		}
+	}
+
+	return c
+}
+
+func TestJWTVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherRSA, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewJWTVerifier(JWTOpts{
+		Secret:     secret,
+		PublicKeys: []crypto.PublicKey{&rsaKey.PublicKey, edPub},
+		Issuer:     "https://issuer.test",
+		Audience:   "scaffold",
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"hs256", sign(t, jwt.SigningMethodHS256, secret, "", claims(nil)), true},
+		{"rs256", sign(t, jwt.SigningMethodRS256, rsaKey, "", claims(nil)), true},
+		{"eddsa", sign(t, jwt.SigningMethodEdDSA, edKey, "", claims(nil)), true},
+		{"wrong secret", sign(t, jwt.SigningMethodHS256, []byte("another secret of sufficient len"), "", claims(nil)), false},
+		{"unknown rsa key", sign(t, jwt.SigningMethodRS256, otherRSA, "", claims(nil)), false},
+		{"expired", sign(t, jwt.SigningMethodHS256, secret, "", claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})), false},
+		{"no expiry", sign(t, jwt.SigningMethodHS256, secret, "", claims(jwt.MapClaims{"exp": nil})), false},
+		{"not yet valid", sign(t, jwt.SigningMethodHS256, secret, "", claims(jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()})), false},
+		{"wrong issuer", sign(t, jwt.SigningMethodHS256, secret, "", claims(jwt.MapClaims{"iss": "https://evil.test"})), false},
+		{"wrong audience", sign(t, jwt.SigningMethodHS256, secret, "", claims(jwt.MapClaims{"aud": "other"})), false},
+		{"hs384 not allowed", sign(t, jwt.SigningMethodHS384, secret, "", claims(nil)), false},
+		{"none", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, "", claims(nil)), false},
+		{"garbage", "not.a.token", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := v.Verify(tt.token)
+
+			if !tt.ok {
+				var unauthorized scaffoldhttp.ErrUnauthorized
+
+				if !errors.As(err, &unauthorized) {
+					t.Fatalf("expected ErrUnauthorized, got %v", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p.Subject != "alice" || p.Method != "jwt" {
+				t.Fatalf("unexpected principal %+v", p)
+			}
+		})
+	}
+}
+
+func TestJWTAllowMissingExpiry(t *testing.T) {
+	v, err := NewJWTVerifier(JWTOpts{Secret: secret, AllowMissingExpiry: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := v.Verify(sign(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "alice"})); err != nil {
+		t.Fatalf("token without expiry was rejected: %v", err)
+	}
+
+	if _, err := v.Verify(sign(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Minute).Unix()})); err == nil {
+		t.Fatal("expired token was accepted")
+	}
+}
+
+func TestJWTKid(t *testing.T) {
+	current, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := JWKS{Keys: []JWK{rsaJWK("current", &current.PublicKey), rsaJWK("previous", &previous.PublicKey)}}
+	b, err := json.Marshal(set)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := NewJWTVerifier(JWTOpts{JWKSFile: path})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key  *rsa.PrivateKey
+		kid  string
+		ok   bool
+	}{
+		{"matching kid", current, "current", true},
+		{"other matching kid", previous, "previous", true},
+		{"unknown kid falls back to all keys", previous, "retired", true},
+		{"no kid falls back to all keys", current, "", true},
+		{"kid of another key", previous, "current", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(sign(t, jwt.SigningMethodRS256, tt.key, tt.kid, jwt.MapClaims{"sub": "svc", "exp": time.Now().Add(time.Hour).Unix()}))
+
+			if (err == nil) != tt.ok {
+				t.Fatalf("expected ok=%v, got %v", tt.ok, err)
+			}
+		})
+	}
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) JWK {
+	return JWK{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func TestJWTRoles(t *testing.T) {
+	v, err := NewJWTVerifier(JWTOpts{Secret: secret, RolesClaim: "groups"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		groups any
+		roles  []string
+	}{
+		{"array", []string{"admin", "editor"}, []string{"admin", "editor"}},
+		{"space separated", "admin editor", []string{"admin", "editor"}},
+		{"missing", nil, nil},
+		{"wrong type", 42, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+
+			if tt.groups != nil {
+				c["groups"] = tt.groups
+			}
+
+			p, err := v.Verify(sign(t, jwt.SigningMethodHS256, secret, "", c))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(p.Roles) != len(tt.roles) {
+				t.Fatalf("expected roles %v, got %v", tt.roles, p.Roles)
+			}
+
+			for i := range tt.roles {
+				if p.Roles[i] != tt.roles[i] {
+					t.Fatalf("expected roles %v, got %v", tt.roles, p.Roles)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	v, err := NewJWTVerifier(JWTOpts{Secret: secret})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(Middleware(v))
+	r.GET("/open", func(c *gin.Context) {
+		p, _ := FromContext(c)
+		c.JSON(http.StatusOK, p)
+	})
+	r.GET("/admin", RequireRole("admin"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	valid := sign(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "alice", "roles": []string{"editor"}, "exp": time.Now().Add(time.Hour).Unix()})
+	admin := sign(t, jwt.SigningMethodHS256, secret, "", jwt.MapClaims{"sub": "root", "roles": []string{"admin"}, "exp": time.Now().Add(time.Hour).Unix()})
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		status        int
+	}{
+		{"anonymous passes through", "/open", "", http.StatusOK},
+		{"valid token", "/open", "Bearer " + valid, http.StatusOK},
+		{"invalid token rejected", "/open", "Bearer " + valid + "x", http.StatusUnauthorized},
+		{"other scheme ignored", "/open", "Basic dXNlcjpwYXNz", http.StatusOK},
+		{"role required", "/admin", "", http.StatusUnauthorized},
+		{"role missing", "/admin", "Bearer " + valid, http.StatusForbidden},
+		{"role held", "/admin", "Bearer " + admin, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPasswordHashers(t *testing.T) {
+	hashers := map[string]PasswordHasher{
+		"bcrypt": BcryptHasher{Cost: 4},
+		"argon2": Argon2Hasher{Memory: 8 * 1024},
+	}
+
+	for name, h := range hashers {
+		t.Run(name, func(t *testing.T) {
+			hash, err := h.Hash("correct horse")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.Contains(hash, "correct horse") {
+				t.Fatal("hash contains the password")
+			}
+
+			other, err := h.Hash("correct horse")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if hash == other {
+				t.Fatal("hashes of the same password are not salted")
+			}
+
+			tests := []struct {
+				password string
+				ok       bool
+			}{
+				{"correct horse", true},
+				{"correct horsE", false},
+				{"correct horse ", false},
+				{"", false},
+			}
+
+			for _, tt := range tests {
+				ok, err := VerifyPassword(hash, tt.password)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if ok != tt.ok {
+					t.Fatalf("VerifyPassword(%q) = %v, expected %v", tt.password, ok, tt.ok)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyPasswordMalformed(t *testing.T) {
+	tests := []string{
+		"",
+		"plaintext",
+		"$argon2id$v=19$m=8192,t=1,p=4$c2FsdA",
+		"$argon2id$v=18$m=8192,t=1,p=4$c2FsdA$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=4$c2FsdA$a2V5",
+		"$argon2id$v=19$m=8192,t=1,p=4$!!!$a2V5",
+	}
+
+	for _, hash := range tests {
+		ok, err := VerifyPassword(hash, "password")
+
+		if ok || err == nil {
+			t.Errorf("VerifyPassword(%q) = %v, %v, expected an error", hash, ok, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is the gin key under which the request principal is stored.
+const ContextKey = "principal"
+
+type principalKey struct{}
+
+// Principal is the authenticated identity attached to a request.
+type Principal struct {
+	Subject string         `bson:"subject" json:"subject"`
+	Method  string         `bson:"method" json:"method"`
+	Roles   []string       `bson:"roles,omitempty" json:"roles,omitempty"`
+	Scopes  []Scope        `bson:"scopes,omitempty" json:"scopes,omitempty"`
+	Claims  map[string]any `bson:"claims,omitempty" json:"claims,omitempty"`
+}
+
+func (p *Principal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithPrincipal returns a copy of ctx carrying the given principal.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal attached to ctx, if any. Both plain contexts created with
+// WithPrincipal and *gin.Context values passed to collection hooks are supported.
+func FromContext(ctx context.Context) (*Principal, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+
+	if p, ok := ctx.Value(principalKey{}).(*Principal); ok && p != nil {
+		return p, true
+	}
+
+	if p, ok := ctx.Value(ContextKey).(*Principal); ok && p != nil {
+		return p, true
+	}
+
+	return nil, false
+}
+
+// Set attaches the principal to the gin context and its underlying request context.
+func Set(c *gin.Context, p *Principal) {
+	c.Set(ContextKey, p)
+	c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), p))
+
+	http.AddLogAttrs(c, slog.String("principal", p.Method+":"+p.Subject))
+}
@@ -0,0 +1,71 @@
+package auth
+
+// Operation is an action performed against a collection.
+type Operation string
+
+const (
+	OpCreate Operation = "create"
+	OpRead   Operation = "read"
+	OpList   Operation = "list"
+	OpUpdate Operation = "update"
+	OpDelete Operation = "delete"
+	// OpAll matches every operation.
+	OpAll Operation = "*"
+)
+
+// Scope limits a principal to a set of operations on a collection. A Collection of "*" matches
+// every collection.
+type Scope struct {
+	Collection string      `bson:"collection" json:"collection"`
+	Operations []Operation `bson:"operations" json:"operations"`
+}
+
+func (s Scope) Allows(slug string, op Operation) bool {
+	if s.Collection != "*" && s.Collection != slug {
+		return false
+	}
+
+	for _, o := range s.Operations {
+		if o == OpAll || o == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Allows reports whether the principal's scopes permit op on the collection slug. Principals
+// without scopes are unrestricted.
+func (p *Principal) Allows(slug string, op Operation) bool {
+	if p == nil || p.Scopes == nil {
+		return true
+	}
+
+	for _, s := range p.Scopes {
+		if s.Allows(slug, op) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Permissions maps role names to the operations holders of that role may perform.
+type Permissions map[string][]Operation
+
+// Allows reports whether any of the principal's roles grants op.
+func (perms Permissions) Allows(p *Principal, op Operation) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, role := range p.Roles {
+		for _, o := range perms[role] {
+			if o == OpAll || o == op {
+				return true
+			}
+		}
+	}
+
+	return false
+}
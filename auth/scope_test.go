@@ -0,0 +1,66 @@
+package auth
+
+import "testing"
+
+func TestScopes(t *testing.T) {
+	scoped := &Principal{Scopes: []Scope{
+		{Collection: "notes", Operations: []Operation{OpRead, OpList}},
+		{Collection: "tasks", Operations: []Operation{OpAll}},
+	}}
+	wildcard := &Principal{Scopes: []Scope{{Collection: "*", Operations: []Operation{OpRead}}}}
+	none := &Principal{Scopes: []Scope{}}
+
+	tests := []struct {
+		name string
+		p    *Principal
+		slug string
+		op   Operation
+		ok   bool
+	}{
+		{"granted operation", scoped, "notes", OpRead, true},
+		{"other operation", scoped, "notes", OpDelete, false},
+		{"all operations", scoped, "tasks", OpDelete, true},
+		{"other collection", scoped, "users", OpRead, false},
+		{"wildcard collection", wildcard, "users", OpRead, true},
+		{"wildcard other operation", wildcard, "users", OpCreate, false},
+		{"empty scopes", none, "notes", OpRead, false},
+		{"unscoped principal", &Principal{}, "notes", OpDelete, true},
+		{"anonymous", nil, "notes", OpDelete, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok := tt.p.Allows(tt.slug, tt.op); ok != tt.ok {
+				t.Fatalf("Allows(%s, %s) = %v, expected %v", tt.slug, tt.op, ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	perms := Permissions{
+		"viewer": {OpRead, OpList},
+		"admin":  {OpAll},
+	}
+
+	tests := []struct {
+		name string
+		p    *Principal
+		op   Operation
+		ok   bool
+	}{
+		{"granted", &Principal{Roles: []string{"viewer"}}, OpList, true},
+		{"not granted", &Principal{Roles: []string{"viewer"}}, OpDelete, false},
+		{"all", &Principal{Roles: []string{"viewer", "admin"}}, OpDelete, true},
+		{"unknown role", &Principal{Roles: []string{"guest"}}, OpRead, false},
+		{"anonymous", nil, OpRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if ok := perms.Allows(tt.p, tt.op); ok != tt.ok {
+				t.Fatalf("Allows(%s) = %v, expected %v", tt.op, ok, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package scaffold
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	nethttp "net/http"
+	"strings"
+	"time"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/alexsobiek/scaffold/query"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// changeHeartbeat is how often an idle change feed sends a comment so proxies keep it open.
+const changeHeartbeat = 15 * time.Second
+
+type ChangeType string
+
+const (
+	ChangeCreate ChangeType = "create"
+	ChangeUpdate ChangeType = "update"
+	ChangeDelete ChangeType = "delete"
+)
+
+// Change is a document created, updated or deleted in a collection.
+type Change[T any] struct {
+	Type ChangeType
+	ID   primitive.ObjectID
+	// Document is the document after the change, passed through the read hook. It is nil for
+	// deletions.
+	Document *Document[T]
+	// Token resumes watching after this change, see C.Watch.
+	Token string
+}
+
+// ChangeStream iterates over the changes to a collection, see C.Watch.
+type ChangeStream[T any] struct {
+	c      *C[T]
+	cs     *mongo.ChangeStream
+	change Change[T]
+	err    error
+}
+
+// Watch opens a stream of the changes to documents matching q, or to all documents when q is
+// nil. Changes are passed through the access and read hooks, and those the access hook rejects
+// are skipped. Documents are upcast but never written back, as the write would be a change of its
+// own. When token is set the stream resumes after the change it was taken from.
+//
+// Watching requires a replica set. Deletions only carry the deleted document's fields, which q
+// and tenancy are matched against, when the collection has changeStreamPreAndPostImages enabled,
+// so deletions are skipped when either filters the stream and pre-images are unavailable.
+func (c *C[T]) Watch(ctx context.Context, q query.Query, token string) (*ChangeStream[T], error) {
+	filter := bson.M{}
+
+	if q != nil {
+		filter = q.Filter()
+	}
+
+	filter = c.filter(ctx, filter)
+
+	match := func(ops bson.M, prefix string) bson.M {
+		if len(filter) == 0 {
+			return ops
+		}
+
+		return bson.M{"$and": []bson.M{ops, prefixFilter(filter, prefix)}}
+	}
+
+	deletes := bson.M{"operationType": "delete"}
+
+	// Filters such as the tenantless scope or $ne also match a missing pre-image, which would
+	// pass every deletion
+	if len(filter) > 0 {
+		deletes["fullDocumentBeforeChange"] = bson.M{"$type": "object"}
+	}
+
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{"$or": []bson.M{
+		match(bson.M{"operationType": bson.M{"$in": []string{"insert", "update", "replace"}}}, "fullDocument."),
+		match(deletes, "fullDocumentBeforeChange."),
+	}}}}}
+
+	opts := options.ChangeStream().
+		SetFullDocument(options.UpdateLookup).
+		SetFullDocumentBeforeChange(options.WhenAvailable)
+
+	if token != "" {
+		opts.SetStartAfter(bson.M{"_data": token})
+	}
+
+	if cm, ok := comment(ctx).(string); ok {
+		opts.SetComment(cm)
+	}
+
+	opCtx, done := c.op(ctx, "watch")
+	cs, err := c.collection(ctx).Watch(opCtx, pipeline, opts)
+	done(err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChangeStream[T]{c: c, cs: cs}, nil
+}
+
+// prefixFilter rewrites the field names of a filter so it applies to a subdocument, descending
+// into logical operators.
+func prefixFilter(filter bson.M, prefix string) bson.M {
+	prefixed := bson.M{}
+
+	for k, v := range filter {
+		if !strings.HasPrefix(k, "$") {
+			prefixed[prefix+k] = v
+			continue
+		}
+
+		switch v := v.(type) {
+		case []bson.M:
+			filters := make([]bson.M, len(v))
+
+			for i := range v {
+				filters[i] = prefixFilter(v[i], prefix)
+			}
+
+			prefixed[k] = filters
+		case bson.M:
+			prefixed[k] = prefixFilter(v, prefix)
+		default:
+			prefixed[k] = v
+		}
+	}
+
+	return prefixed
+}
+
+// Next waits for the next change, returning false once the stream is closed, ctx is done or an
+// error occurred.
+func (s *ChangeStream[T]) Next(ctx context.Context) bool {
+	for s.err == nil && s.cs.Next(ctx) {
+		change, ok, err := s.decode(ctx)
+
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		if ok {
+			s.change = change
+			return true
+		}
+	}
+
+	return false
+}
+
+// decode reads the current event, reporting false when it should be skipped.
+func (s *ChangeStream[T]) decode(ctx context.Context) (Change[T], bool, error) {
+	var event struct {
+		OperationType string   `bson:"operationType"`
+		FullDocument  bson.Raw `bson:"fullDocument"`
+		DocumentKey   struct {
+			ID primitive.ObjectID `bson:"_id"`
+		} `bson:"documentKey"`
+	}
+
+	if err := s.cs.Decode(&event); err != nil {
+		return Change[T]{}, false, err
+	}
+
+	change := Change[T]{ID: event.DocumentKey.ID}
+	change.Token, _ = s.cs.Current.Lookup("_id", "_data").StringValueOK()
+
+	switch event.OperationType {
+	case "insert":
+		change.Type = ChangeCreate
+	case "update", "replace":
+		change.Type = ChangeUpdate
+	case "delete":
+		change.Type = ChangeDelete
+	default:
+		return change, false, nil
+	}
+
+	if change.Type != ChangeDelete && len(event.FullDocument) == 0 {
+		// The document was deleted before its update could be looked up
+		return change, false, nil
+	}
+
+	if err := s.c.access(ctx, change.ID); err != nil {
+		return change, false, nil
+	}
+
+	if change.Type == ChangeDelete {
+		return change, true, nil
+	}
+
+	// Writing the upcast document back would itself be reported as an update
+	doc, _, err := s.c.unmarshal(event.FullDocument)
+
+	if err != nil {
+		return change, false, err
+	}
+
+	doc.Data, err = s.c.read(ctx, doc.ID, doc.Data)
+
+	if err != nil {
+		return change, false, err
+	}
+
+	change.Document = doc
+
+	return change, true, nil
+}
+
+// Change returns the change Next moved to.
+func (s *ChangeStream[T]) Change() Change[T] {
+	return s.change
+}
+
+func (s *ChangeStream[T]) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	return s.cs.Err()
+}
+
+func (s *ChangeStream[T]) Close(ctx context.Context) error {
+	return s.cs.Close(ctx)
+}
+
+// handleChanges streams changes to the documents matching the request's filters as Server-Sent
+// Events, resuming after the Last-Event-ID a reconnecting client sends.
+func (c *C[T]) handleChanges(ctx *gin.Context) {
+	if !c.authorize(ctx, auth.OpList) {
+		return
+	}
+
+	q, err := c.fields.Parse(ctx.Request.URL.Query())
+
+	if err != nil {
+		http.BadRequest(ctx, err)
+		return
+	}
+
+	token := ctx.GetHeader("Last-Event-ID")
+
+	if _, err := hex.DecodeString(token); err != nil {
+		http.BadRequest(ctx, errors.New("invalid Last-Event-ID"))
+		return
+	}
+
+	// Changes are read and passed through the hooks on another goroutine, so it is given the
+	// request's context rather than ctx, which this one writes the response through
+	watchCtx, cancel := context.WithCancel(ctx.Request.Context())
+	defer cancel()
+
+	cs, err := c.Watch(watchCtx, q, token)
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	changes := make(chan Change[T])
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		defer close(changes)
+
+		for cs.Next(watchCtx) {
+			select {
+			case changes <- cs.Change():
+			case <-watchCtx.Done():
+				return
+			}
+		}
+	}()
+
+	defer func() {
+		cancel()
+		<-done
+
+		if err := cs.Err(); err != nil && !errors.Is(err, context.Canceled) {
+			http.RecordError(ctx, err)
+		}
+
+		cs.Close(context.WithoutCancel(ctx))
+	}()
+
+	h := ctx.Writer.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no")
+	ctx.Status(nethttp.StatusOK)
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(changeHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+
+			if err := writeChange(ctx.Writer, change); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(ctx.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-c.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		ctx.Writer.Flush()
+	}
+}
+
+// writeChange writes a change as an event named after its type, whose data is the document or,
+// for deletions, its ID.
+func writeChange[T any](w gin.ResponseWriter, change Change[T]) error {
+	var data any = change.Document
+
+	if change.Type == ChangeDelete {
+		data = gin.H{"id": change.ID}
+	}
+
+	b, err := json.Marshal(data)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", change.Token, change.Type, b)
+
+	return err
+}
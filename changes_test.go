@@ -0,0 +1,341 @@
+package scaffold
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexsobiek/scaffold/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// requireChangeStreams skips t unless the Mongo server supports change streams, which need a
+// replica set.
+func requireChangeStreams(t *testing.T, s *Scaffold) {
+	t.Helper()
+
+	ctx := context.Background()
+	cs, err := s.Database().Collection("_watch").Watch(ctx, mongo.Pipeline{})
+
+	if err != nil {
+		t.Skipf("change streams are unavailable: %v", err)
+	}
+
+	cs.Close(ctx)
+}
+
+type sseEvent struct {
+	id    string
+	event string
+	data  string
+	// raw is the event as written, for checking its framing
+	raw string
+}
+
+// feed opens the change feed at path on srv, returning a function waiting for its next event.
+// Heartbeats are skipped.
+func feed(t *testing.T, srv *httptest.Server, path string, headers ...string) func() sseEvent {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := nethttp.NewRequestWithContext(ctx, "GET", srv.URL+path, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Add(headers[i], headers[i+1])
+	}
+
+	res, err := srv.Client().Do(req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { res.Body.Close() })
+
+	if res.StatusCode != nethttp.StatusOK || res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("feed responded %d with %s", res.StatusCode, res.Header.Get("Content-Type"))
+	}
+
+	events := make(chan sseEvent)
+
+	go func() {
+		defer close(events)
+
+		r := bufio.NewReader(res.Body)
+
+		var e sseEvent
+
+		for {
+			line, err := r.ReadString('\n')
+
+			if err != nil {
+				return
+			}
+
+			if strings.HasPrefix(line, ":") {
+				continue
+			}
+
+			e.raw += line
+
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				e.id = strings.TrimSuffix(strings.TrimPrefix(line, "id: "), "\n")
+			case strings.HasPrefix(line, "event: "):
+				e.event = strings.TrimSuffix(strings.TrimPrefix(line, "event: "), "\n")
+			case strings.HasPrefix(line, "data: "):
+				e.data = strings.TrimSuffix(strings.TrimPrefix(line, "data: "), "\n")
+			case line == "\n" && e.raw == "\n":
+				// The blank line ending a heartbeat
+				e = sseEvent{}
+			case line == "\n":
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+
+				e = sseEvent{}
+			}
+		}
+	}()
+
+	return func() sseEvent {
+		t.Helper()
+
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatal("feed closed")
+			}
+
+			return e
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for a change")
+		}
+
+		return sseEvent{}
+	}
+}
+
+// expectChange checks that e is a change of type to the document id, reporting the text of
+// the document for creations and updates.
+func expectChange(t *testing.T, e sseEvent, typ ChangeType, id primitive.ObjectID) string {
+	t.Helper()
+
+	var data struct {
+		ID       string `json:"id"`
+		Document note   `json:"document"`
+	}
+
+	if err := json.Unmarshal([]byte(e.data), &data); err != nil {
+		t.Fatalf("invalid event data %q: %v", e.data, err)
+	}
+
+	if ChangeType(e.event) != typ || data.ID != id.Hex() {
+		t.Fatalf("expected %s of %s, got %s of %s", typ, id.Hex(), e.event, data.ID)
+	}
+
+	return data.Document.Text
+}
+
+func TestChanges(t *testing.T) {
+	var mu sync.Mutex
+	denied := map[primitive.ObjectID]bool{}
+
+	notes := NewCollection(CollectionOpts[note]{
+		Name: "Notes",
+		Slug: "notes",
+		Access: func(_ context.Context, id primitive.ObjectID) error {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if denied[id] {
+				return fmt.Errorf("%s is denied", id.Hex())
+			}
+
+			return nil
+		},
+		Read: func(_ context.Context, _ primitive.ObjectID, data *note) (*note, error) {
+			data.Text += " (read)"
+			return data, nil
+		},
+	})
+
+	s, h := testScaffold(t, ScaffoldOpts{Collections: []Collection{notes}})
+	requireChangeStreams(t, s)
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	next := feed(t, srv, "/notes/_changes")
+
+	a, err := notes.Insert(ctx, note{Text: "a"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := next()
+
+	if text := expectChange(t, created, ChangeCreate, a.ID); text != "a (read)" {
+		t.Fatalf("created document is %q", text)
+	}
+
+	if created.id == "" || created.raw != fmt.Sprintf("id: %s\nevent: create\ndata: %s\n\n", created.id, created.data) {
+		t.Fatalf("malformed event %q", created.raw)
+	}
+
+	if err := a.Set(ctx, "text", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := next()
+
+	if text := expectChange(t, updated, ChangeUpdate, a.ID); text != "b (read)" {
+		t.Fatalf("updated document is %q", text)
+	}
+
+	hidden, err := notes.Insert(ctx, note{Text: "hidden"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectChange(t, next(), ChangeCreate, hidden.ID)
+
+	// Changes the access hook rejects are skipped
+	mu.Lock()
+	denied[hidden.ID] = true
+	mu.Unlock()
+
+	if err := hidden.Set(ctx, "text", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := a.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted := next()
+	expectChange(t, deleted, ChangeDelete, a.ID)
+
+	if deleted.data != fmt.Sprintf(`{"id":%q}`, a.ID.Hex()) {
+		t.Fatalf("deletion carries %s", deleted.data)
+	}
+
+	t.Run("resume", func(t *testing.T) {
+		resumed := feed(t, srv, "/notes/_changes", "Last-Event-ID", created.id)
+
+		if e := resumed(); e.id != updated.id {
+			t.Fatalf("resumed at %s, expected %s", e.id, updated.id)
+		}
+
+		expectChange(t, resumed(), ChangeCreate, hidden.ID)
+		expectChange(t, resumed(), ChangeDelete, a.ID)
+	})
+
+	t.Run("invalid Last-Event-ID", func(t *testing.T) {
+		expectStatus(t, request(h, "GET", "/notes/_changes", nil, "Last-Event-ID", "not-a-token"), nethttp.StatusBadRequest)
+	})
+}
+
+func TestChangesTenancy(t *testing.T) {
+	notes := NewCollection(CollectionOpts[note]{Name: "Notes", Slug: "notes"})
+
+	s, h := testScaffold(t, ScaffoldOpts{
+		Collections: []Collection{notes},
+		Tenancy:     &TenancyOpts{Optional: true},
+	})
+
+	requireChangeStreams(t, s)
+
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	acme := tenant.With(context.Background(), "acme")
+	globex := tenant.With(context.Background(), "globex")
+	shared := context.Background()
+
+	insert := func(ctx context.Context, text string) *Document[note] {
+		t.Helper()
+
+		doc, err := notes.Insert(ctx, note{Text: text})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return doc
+	}
+
+	remove := func(ctx context.Context, doc *Document[note]) {
+		t.Helper()
+
+		if err := doc.Delete(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	acme1, globex1 := insert(acme, "acme 1"), insert(globex, "globex 1")
+
+	acmeFeed := feed(t, srv, "/notes/_changes", "X-Tenant-ID", "acme")
+	sharedFeed := feed(t, srv, "/notes/_changes")
+
+	insert(globex, "globex 2")
+	acme2 := insert(acme, "acme 2")
+	shared2 := insert(shared, "shared 2")
+
+	expectChange(t, acmeFeed(), ChangeCreate, acme2.ID)
+	expectChange(t, sharedFeed(), ChangeCreate, shared2.ID)
+
+	// Without pre-images deletions cannot be matched against the tenant, so neither feed receives
+	// them
+	remove(globex, globex1)
+	remove(acme, acme1)
+
+	acme3 := insert(acme, "acme 3")
+	shared3 := insert(shared, "shared 3")
+
+	expectChange(t, acmeFeed(), ChangeCreate, acme3.ID)
+	expectChange(t, sharedFeed(), ChangeCreate, shared3.ID)
+
+	t.Run("pre-images", func(t *testing.T) {
+		err := s.Database().db.RunCommand(context.Background(), bson.D{
+			{Key: "collMod", Value: "notes"},
+			{Key: "changeStreamPreAndPostImages", Value: bson.M{"enabled": true}},
+		}).Err()
+
+		if err != nil {
+			t.Skipf("pre-images are unavailable: %v", err)
+		}
+
+		remove(acme, acme2)
+		remove(shared, shared2)
+
+		acme4 := insert(acme, "acme 4")
+		shared4 := insert(shared, "shared 4")
+
+		expectChange(t, acmeFeed(), ChangeDelete, acme2.ID)
+		expectChange(t, acmeFeed(), ChangeCreate, acme4.ID)
+
+		expectChange(t, sharedFeed(), ChangeDelete, shared2.ID)
+		expectChange(t, sharedFeed(), ChangeCreate, shared4.ID)
+	})
+}
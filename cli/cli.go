@@ -0,0 +1,268 @@
+// Package cli implements the scaffold command line tool. It can run standalone against any
+// database with Main, or be embedded in an application's main with Run to also know its
+// registered collections.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alexsobiek/scaffold"
+)
+
+type CLI struct {
+	Scaffold *scaffold.Scaffold
+	Stdin    io.Reader
+	Stdout   io.Writer
+	Stderr   io.Writer
+}
+
+type command struct {
+	usage       string
+	description string
+	// db commands connect to Mongo before running
+	db  bool
+	run func(ctx context.Context, c *CLI, db *scaffold.Database, fs *flag.FlagSet) error
+	// flags registers command flags, parsed before run
+	flags func(fs *flag.FlagSet)
+}
+
+var commands = map[string]*command{}
+
+// Run runs the command in args against s using the standard streams.
+func Run(ctx context.Context, s *scaffold.Scaffold, args []string) error {
+	c := &CLI{Scaffold: s, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+	return c.Run(ctx, args)
+}
+
+// Main runs the standalone tool, connecting with the -uri and -database flags, which default to
+// the MONGO_URI and MONGO_DATABASE environment variables.
+func Main(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("scaffold", flag.ContinueOnError)
+	uri := fs.String("uri", os.Getenv("MONGO_URI"), "Mongo connection string")
+	database := fs.String("database", os.Getenv("MONGO_DATABASE"), "database name")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: scaffold [-uri uri] [-database name] <command> [args]")
+		fs.PrintDefaults()
+		printCommands(fs.Output())
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	s := scaffold.New(scaffold.ScaffoldOpts{MongoURI: *uri, Database: *database})
+
+	return Run(ctx, s, fs.Args())
+}
+
+// Run runs the command in args.
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printCommands(c.Stderr)
+		return flag.ErrHelp
+	}
+
+	cmd, ok := commands[args[0]]
+
+	if !ok {
+		printCommands(c.Stderr)
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s\n\n%s\n", cmd.usage, cmd.description)
+		fs.PrintDefaults()
+	}
+
+	if cmd.flags != nil {
+		cmd.flags(fs)
+	}
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var db *scaffold.Database
+
+	if cmd.db {
+		db = c.Scaffold.Database()
+	}
+
+	// Connections shared with a running application are left open
+	if cmd.db && db == nil {
+		var err error
+		db, err = c.Scaffold.Connect(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		defer db.Close(context.WithoutCancel(ctx))
+	}
+
+	return cmd.run(ctx, c, db, fs)
+}
+
+func printCommands(w io.Writer) {
+	var names []string
+
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "\nCommands:")
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", commands[name].usage, commands[name].description)
+	}
+
+	tw.Flush()
+}
+
+// arg returns the i-th positional argument, or an error naming it when missing.
+func arg(fs *flag.FlagSet, i int, name string) (string, error) {
+	if fs.NArg() <= i {
+		return "", fmt.Errorf("missing %s argument", name)
+	}
+
+	return fs.Arg(i), nil
+}
+
+func init() {
+	commands["collections"] = &command{
+		usage:       "collections",
+		description: "List collections with their document counts",
+		db:          true,
+		run:         listCollections,
+	}
+
+	commands["indexes"] = &command{
+		usage:       "indexes <collection>",
+		description: "Show the indexes of a collection",
+		db:          true,
+		run:         listIndexes,
+	}
+
+	commands["openapi"] = &command{
+		usage:       "openapi",
+		description: "Print the OpenAPI document describing the registered collections",
+		run: func(_ context.Context, c *CLI, _ *scaffold.Database, _ *flag.FlagSet) error {
+			enc := json.NewEncoder(c.Stdout)
+			enc.SetIndent("", "  ")
+
+			return enc.Encode(c.Scaffold.OpenAPI())
+		},
+	}
+
+	commands["typescript"] = &command{
+		usage:       "typescript",
+		description: "Print TypeScript types and a client for the registered collections",
+		run: func(_ context.Context, c *CLI, _ *scaffold.Database, _ *flag.FlagSet) error {
+			return c.Scaffold.TypeScript(c.Stdout)
+		},
+	}
+}
+
+func listCollections(ctx context.Context, c *CLI, db *scaffold.Database, _ *flag.FlagSet) error {
+	names, err := db.CollectionNames(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	registered := map[string]string{}
+
+	for _, col := range c.Scaffold.Collections() {
+		slug := col.Slug()
+		registered[slug] = col.Name()
+
+		// List registered collections even before their first document is stored
+		if !contains(names, slug) {
+			names = append(names, slug)
+		}
+	}
+
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(c.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "COLLECTION\tNAME\tDOCUMENTS")
+
+	for _, name := range names {
+		count, err := db.Collection(name).EstimatedDocumentCount(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%d\n", name, registered[name], count)
+	}
+
+	return tw.Flush()
+}
+
+func listIndexes(ctx context.Context, c *CLI, db *scaffold.Database, fs *flag.FlagSet) error {
+	name, err := arg(fs, 0, "collection")
+
+	if err != nil {
+		return err
+	}
+
+	specs, err := db.Collection(name).Indexes().ListSpecifications(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if len(specs) == 0 {
+		return errors.New("collection has no indexes")
+	}
+
+	tw := tabwriter.NewWriter(c.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tKEYS\tOPTIONS")
+
+	for _, spec := range specs {
+		var opts []string
+
+		if spec.Unique != nil && *spec.Unique {
+			opts = append(opts, "unique")
+		}
+
+		if spec.Sparse != nil && *spec.Sparse {
+			opts = append(opts, "sparse")
+		}
+
+		if spec.ExpireAfterSeconds != nil {
+			opts = append(opts, fmt.Sprintf("ttl=%ds", *spec.ExpireAfterSeconds))
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", spec.Name, spec.KeysDocument, strings.Join(opts, ","))
+	}
+
+	return tw.Flush()
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/alexsobiek/scaffold"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type note struct {
+	Text string `bson:"text" json:"text"`
+}
+
+// failingWriter fails every write, like a full disk or closed pipe.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestRunSharedConnection(t *testing.T) {
+	uri := os.Getenv("MONGO_URI")
+
+	if uri == "" {
+		t.Skip("MONGO_URI is not set")
+	}
+
+	ctx := context.Background()
+	notes := scaffold.NewCollection(scaffold.CollectionOpts[note]{Name: "Notes", Slug: "notes"})
+	s := scaffold.New(scaffold.ScaffoldOpts{
+		MongoURI:    uri,
+		Database:    "scaffold_test_" + primitive.NewObjectID().Hex(),
+		Collections: []scaffold.Collection{notes},
+		LogHandler:  slog.NewTextHandler(io.Discard, nil),
+	})
+
+	if _, err := s.Handler(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		s.Database().Collection("notes").Database().Drop(ctx)
+		s.Shutdown(ctx)
+	})
+
+	if _, err := notes.Insert(ctx, note{Text: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	c := &CLI{Scaffold: s, Stdout: &out, Stderr: io.Discard}
+
+	if err := c.Run(ctx, []string{"export", "notes"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Contains(out.Bytes(), []byte(`"hello"`)) {
+		t.Fatalf("exported %s", out.String())
+	}
+
+	// The application's connection is still usable after the command
+	if _, err := notes.Insert(ctx, note{Text: "after"}); err != nil {
+		t.Fatalf("command closed the application's connection: %v", err)
+	}
+
+	c.Stdout = failingWriter{}
+
+	if err := c.Run(ctx, []string{"export", "notes"}); err == nil {
+		t.Fatal("export ignored a failing writer")
+	}
+}
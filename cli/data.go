@@ -0,0 +1,280 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"unicode"
+
+	"github.com/alexsobiek/scaffold"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func init() {
+	commands["seed"] = &command{
+		usage:       "seed <collection> <file>",
+		description: "Insert fixtures which are not already present, from a JSON array or JSON lines file in Extended JSON",
+		db:          true,
+		run:         seed,
+	}
+
+	commands["export"] = &command{
+		usage:       "export <collection> [file]",
+		description: "Write all documents as canonical Extended JSON lines, to stdout by default",
+		db:          true,
+		run:         export,
+	}
+
+	commands["import"] = &command{
+		usage:       "import [-replace] <collection> [file]",
+		description: "Insert documents from Extended JSON lines, from stdin by default",
+		db:          true,
+		flags: func(fs *flag.FlagSet) {
+			fs.Bool("replace", false, "replace existing documents with the same _id")
+		},
+		run: importDocuments,
+	}
+}
+
+func seed(ctx context.Context, c *CLI, db *scaffold.Database, fs *flag.FlagSet) error {
+	name, err := arg(fs, 0, "collection")
+
+	if err != nil {
+		return err
+	}
+
+	file, err := arg(fs, 1, "file")
+
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(file)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	col := db.Collection(name)
+	now := primitive.NewDateTimeFromTime(time.Now())
+	inserted, total := 0, 0
+
+	err = readDocuments(f, func(doc bson.D) error {
+		total++
+
+		doc = withDefault(doc, "_id", primitive.NewObjectID())
+		doc = withDefault(doc, "created", now)
+		doc = withDefault(doc, "last_updated", now)
+
+		res, err := col.UpdateOne(ctx, bson.M{"_id": id(doc)}, bson.M{"$setOnInsert": doc}, options.Update().SetUpsert(true))
+
+		if err != nil {
+			return err
+		}
+
+		inserted += int(res.UpsertedCount)
+
+		return nil
+	})
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Stdout, "Inserted %d of %d fixtures into %s\n", inserted, total, name)
+
+	return nil
+}
+
+func export(ctx context.Context, c *CLI, db *scaffold.Database, fs *flag.FlagSet) error {
+	name, err := arg(fs, 0, "collection")
+
+	if err != nil {
+		return err
+	}
+
+	out := c.Stdout
+
+	var f *os.File
+
+	if fs.NArg() > 1 {
+		f, err = os.Create(fs.Arg(1))
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		out = f
+	}
+
+	cur, err := db.Collection(name).Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+
+	if err != nil {
+		return err
+	}
+
+	defer cur.Close(ctx)
+
+	w := bufio.NewWriter(out)
+
+	for cur.Next(ctx) {
+		line, err := bson.MarshalExtJSON(cur.Current, true, false)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+
+	if err := cur.Err(); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	// Writes to the file may only fail once it is closed
+	if f != nil {
+		return f.Close()
+	}
+
+	return nil
+}
+
+func importDocuments(ctx context.Context, c *CLI, db *scaffold.Database, fs *flag.FlagSet) error {
+	name, err := arg(fs, 0, "collection")
+
+	if err != nil {
+		return err
+	}
+
+	in := c.Stdin
+
+	if fs.NArg() > 1 {
+		f, err := os.Open(fs.Arg(1))
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+
+		in = f
+	}
+
+	replace := fs.Lookup("replace").Value.String() == "true"
+	col := db.Collection(name)
+	count := 0
+
+	err = readDocuments(in, func(doc bson.D) error {
+		var err error
+
+		if id := id(doc); id != nil && replace {
+			_, err = col.ReplaceOne(ctx, bson.M{"_id": id}, doc, options.Replace().SetUpsert(true))
+		} else {
+			_, err = col.InsertOne(ctx, doc)
+		}
+
+		if err != nil {
+			return fmt.Errorf("document %d: %w", count+1, err)
+		}
+
+		count++
+
+		return nil
+	})
+
+	fmt.Fprintf(c.Stdout, "Imported %d documents into %s\n", count, name)
+
+	return err
+}
+
+// readDocuments calls fn with each document in r, which holds either a JSON array or a sequence
+// of JSON values in Extended JSON.
+func readDocuments(r io.Reader, fn func(bson.D) error) error {
+	br := bufio.NewReader(r)
+
+	var first byte
+
+	for {
+		b, err := br.ReadByte()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !unicode.IsSpace(rune(b)) {
+			first = b
+			br.UnreadByte()
+			break
+		}
+	}
+
+	dec := json.NewDecoder(br)
+
+	if first == '[' {
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+
+	for dec.More() {
+		var raw json.RawMessage
+
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+
+		var doc bson.D
+
+		if err := bson.UnmarshalExtJSON(raw, false, &doc); err != nil {
+			return err
+		}
+
+		if err := fn(doc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func id(doc bson.D) any {
+	for _, e := range doc {
+		if e.Key == "_id" {
+			return e.Value
+		}
+	}
+
+	return nil
+}
+
+// withDefault sets key on doc when it is not present.
+func withDefault(doc bson.D, key string, value any) bson.D {
+	for _, e := range doc {
+		if e.Key == key {
+			return doc
+		}
+	}
+
+	return append(doc, bson.E{Key: key, Value: value})
+}
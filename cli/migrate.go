@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/alexsobiek/scaffold"
+)
+
+func init() {
+	commands["migrate"] = &command{
+		usage:       "migrate status|up|down [-steps n] [-dry-run]",
+		description: "Show, apply or roll back the registered migrations",
+		db:          true,
+		flags: func(fs *flag.FlagSet) {
+			fs.Int("steps", 1, "number of migrations to roll back")
+			fs.Bool("dry-run", false, "list the migrations which would run without running them")
+		},
+		run: migrate,
+	}
+}
+
+func migrate(ctx context.Context, c *CLI, _ *scaffold.Database, fs *flag.FlagSet) error {
+	action, err := arg(fs, 0, "action")
+
+	if err != nil {
+		return err
+	}
+
+	// Allow flags after the action
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return err
+	}
+
+	m := c.Scaffold.Migrations()
+
+	if m == nil {
+		return errors.New("no migrations are registered")
+	}
+
+	dryRun := fs.Lookup("dry-run").Value.String() == "true"
+	steps, _ := strconv.Atoi(fs.Lookup("steps").Value.String())
+
+	var ran []string
+
+	switch action {
+	case "status":
+		return migrationStatus(ctx, c, m)
+	case "up":
+		ran, err = m.Up(ctx, dryRun)
+	case "down":
+		ran, err = m.Down(ctx, steps, dryRun)
+	default:
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+
+	verb := "Ran"
+
+	if dryRun {
+		verb = "Would run"
+	}
+
+	for _, name := range ran {
+		fmt.Fprintf(c.Stdout, "%s %s %s\n", verb, action, name)
+	}
+
+	if err == nil && len(ran) == 0 {
+		fmt.Fprintln(c.Stdout, "No migrations to run")
+	}
+
+	return err
+}
+
+func migrationStatus(ctx context.Context, c *CLI, m *scaffold.Migrations) error {
+	status, err := m.Status(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(c.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "MIGRATION\tAPPLIED\tREVERSIBLE")
+
+	for _, s := range status {
+		applied := "pending"
+
+		if s.Applied != nil {
+			applied = s.Applied.Format(time.RFC3339)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%t\n", s.Name, applied, s.Reversible)
+	}
+
+	return tw.Flush()
+}
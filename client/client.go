@@ -0,0 +1,301 @@
+// Package client is a typed client for collections served by Scaffold.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexsobiek/scaffold/http"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Document is a document as returned by the API.
+type Document[T any] struct {
+	ID          primitive.ObjectID `json:"id"`
+	Created     time.Time          `json:"created"`
+	LastUpdated time.Time          `json:"last_updated"`
+	Data        T                  `json:"document"`
+}
+
+// Page is a page of documents returned by List.
+type Page[T any] struct {
+	Documents []Document[T]
+	Count     int
+	Page      int
+}
+
+// Filter matches documents whose field compares to Value using Op, one of "eq", "ne", "gt",
+// "gte", "lt", "lte", "in" and "nin". Op defaults to "eq".
+type Filter struct {
+	Field string
+	Op    string
+	// Value is formatted for the query string: times as RFC 3339, ObjectIDs as hex and slices
+	// comma separated.
+	Value any
+}
+
+type ListOpts struct {
+	Filters []Filter
+	// Sort lists fields to sort by, descending when prefixed with "-".
+	Sort []string
+	// Limit and Page default to the server defaults when zero.
+	Limit int
+	Page  int
+}
+
+// C is a client for one collection.
+type C[T any] struct {
+	url string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *nethttp.Client
+	// Header is sent with every request, e.g. for authentication.
+	Header nethttp.Header
+}
+
+// Collection returns a client for the collection served at slug under baseURL.
+func Collection[T any](baseURL string, slug string) *C[T] {
+	return &C[T]{
+		url:    strings.TrimSuffix(baseURL, "/") + "/" + strings.Trim(slug, "/"),
+		Header: nethttp.Header{},
+	}
+}
+
+func (c *C[T]) Insert(ctx context.Context, data T) (*Document[T], error) {
+	var res response[Document[T]]
+
+	if err := c.do(ctx, nethttp.MethodPost, "/", data, &res); err != nil {
+		return nil, err
+	}
+
+	return &res.Data, nil
+}
+
+func (c *C[T]) Get(ctx context.Context, id primitive.ObjectID) (*Document[T], error) {
+	var res response[Document[T]]
+
+	if err := c.do(ctx, nethttp.MethodGet, "/"+id.Hex(), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res.Data, nil
+}
+
+func (c *C[T]) List(ctx context.Context, opts ListOpts) (*Page[T], error) {
+	q := url.Values{}
+
+	for _, f := range opts.Filters {
+		key := f.Field
+
+		if f.Op != "" && f.Op != "eq" {
+			key += "[" + f.Op + "]"
+		}
+
+		q.Add(key, formatValue(f.Value))
+	}
+
+	if len(opts.Sort) > 0 {
+		q.Set("sort", strings.Join(opts.Sort, ","))
+	}
+
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+
+	if opts.Page > 0 {
+		q.Set("page", strconv.Itoa(opts.Page))
+	}
+
+	path := "/"
+
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+
+	var page struct {
+		Data  []Document[T] `json:"data"`
+		Count int           `json:"count"`
+		Page  int           `json:"page"`
+	}
+
+	err := c.do(ctx, nethttp.MethodGet, path, nil, &page)
+
+	// The server reports an empty page as a bad request
+	var badRequest http.ErrBadRequest
+
+	if errors.As(err, &badRequest) && badRequest.Message == "no data" {
+		p := opts.Page
+
+		if p == 0 {
+			p = 1
+		}
+
+		return &Page[T]{Page: p}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Page[T]{Documents: page.Data, Count: page.Count, Page: page.Page}, nil
+}
+
+// Patch sets fields of the document, keyed by their bson or Go field name.
+func (c *C[T]) Patch(ctx context.Context, id primitive.ObjectID, fields map[string]any) (*Document[T], error) {
+	var res response[Document[T]]
+
+	if err := c.do(ctx, nethttp.MethodPatch, "/"+id.Hex(), fields, &res); err != nil {
+		return nil, err
+	}
+
+	return &res.Data, nil
+}
+
+// Delete deletes the document, returning it as it was before deletion.
+func (c *C[T]) Delete(ctx context.Context, id primitive.ObjectID) (*Document[T], error) {
+	var res response[Document[T]]
+
+	if err := c.do(ctx, nethttp.MethodDelete, "/"+id.Hex(), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res.Data, nil
+}
+
+// response is the envelope of successful responses.
+type response[D any] struct {
+	Data D `json:"data"`
+}
+
+// do sends a request with body encoded as JSON, decoding the response into out.
+func (c *C[T]) do(ctx context.Context, method string, path string, body any, out any) error {
+	var r io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+
+		if err != nil {
+			return err
+		}
+
+		r = bytes.NewReader(b)
+	}
+
+	req, err := nethttp.NewRequestWithContext(ctx, method, c.url+path, r)
+
+	if err != nil {
+		return err
+	}
+
+	for k, v := range c.Header {
+		req.Header[k] = v
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	hc := c.HTTPClient
+
+	if hc == nil {
+		hc = nethttp.DefaultClient
+	}
+
+	res, err := hc.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return responseError(res)
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// Error is returned for error responses. It unwraps to the http.Err* type matching the status,
+// so callers can use errors.As, e.g. with http.ErrNotFound.
+type Error struct {
+	StatusCode int
+	RequestID  string
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.StatusCode, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func responseError(res *nethttp.Response) error {
+	var body http.Response
+
+	// Fall back to the status text when the body is not an error envelope, e.g. from a proxy
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil || body.Error == "" {
+		body.Error = strings.ToLower(nethttp.StatusText(res.StatusCode))
+	}
+
+	msg := body.Error
+	var err error
+
+	switch res.StatusCode {
+	case nethttp.StatusBadRequest:
+		err = http.ErrBadRequest{Message: msg}
+	case nethttp.StatusUnauthorized:
+		err = http.ErrUnauthorized{Message: msg}
+	case nethttp.StatusForbidden:
+		err = http.ErrForbidden{Message: msg}
+	case nethttp.StatusNotFound:
+		err = http.ErrNotFound{Message: msg}
+	case nethttp.StatusMethodNotAllowed:
+		err = http.ErrMethodNotAllowed{Message: msg}
+	case nethttp.StatusTooManyRequests:
+		err = http.ErrTooManyRequests{Message: msg}
+	default:
+		if res.StatusCode < 500 {
+			err = http.ErrBadRequest{Message: msg}
+		} else {
+			err = http.ErrInternal{Message: msg}
+		}
+	}
+
+	return &Error{StatusCode: res.StatusCode, RequestID: body.RequestID, Err: err}
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case primitive.ObjectID:
+		return v.Hex()
+	case []string:
+		return strings.Join(v, ",")
+	case []any:
+		values := make([]string, len(v))
+
+		for i, item := range v {
+			values[i] = formatValue(item)
+		}
+
+		return strings.Join(values, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
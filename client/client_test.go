@@ -0,0 +1,190 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alexsobiek/scaffold/http"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type note struct {
+	Text string `json:"text"`
+}
+
+// serve returns a client for the collection "notes" on a server answering with handler.
+func serve(t *testing.T, handler nethttp.HandlerFunc) *C[note] {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	return Collection[note](srv.URL+"/api/", "/notes/")
+}
+
+func TestResponseError(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		err       error
+		requestID string
+	}{
+		{"not found", nethttp.StatusNotFound, `{"error":"document not found","request_id":"req-1"}`, http.ErrNotFound{Message: "document not found"}, "req-1"},
+		{"unauthorized", nethttp.StatusUnauthorized, `{"error":"invalid token","request_id":"req-2"}`, http.ErrUnauthorized{Message: "invalid token"}, "req-2"},
+		{"forbidden", nethttp.StatusForbidden, `{"error":"forbidden"}`, http.ErrForbidden{Message: "forbidden"}, ""},
+		{"too many requests", nethttp.StatusTooManyRequests, `{"error":"slow down"}`, http.ErrTooManyRequests{Message: "slow down"}, ""},
+		{"other client error", nethttp.StatusConflict, `{"error":"conflict"}`, http.ErrBadRequest{Message: "conflict"}, ""},
+		{"server error", nethttp.StatusInternalServerError, `{"error":"internal server error","request_id":"req-3"}`, http.ErrInternal{Message: "internal server error"}, "req-3"},
+		{"proxy error", nethttp.StatusBadGateway, `<html>Bad Gateway</html>`, http.ErrInternal{Message: "bad gateway"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := serve(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			_, err := c.Get(context.Background(), primitive.NewObjectID())
+
+			var res *Error
+
+			if !errors.As(err, &res) {
+				t.Fatalf("expected an *Error, got %v", err)
+			}
+
+			if res.StatusCode != tt.status || res.RequestID != tt.requestID || res.Err != tt.err {
+				t.Fatalf("unexpected error %+v", res)
+			}
+		})
+	}
+
+	t.Run("unwraps to the matching type", func(t *testing.T) {
+		c := serve(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			w.WriteHeader(nethttp.StatusNotFound)
+		})
+
+		var notFound http.ErrNotFound
+
+		if _, err := c.Get(context.Background(), primitive.NewObjectID()); !errors.As(err, &notFound) {
+			t.Fatalf("%v does not unwrap to http.ErrNotFound", err)
+		}
+	})
+}
+
+func TestList(t *testing.T) {
+	var query url.Values
+	var path string
+
+	c := serve(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		query, path = r.URL.Query(), r.URL.Path
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"data":  []Document[note]{{ID: primitive.NewObjectID(), Data: note{Text: "ada"}}},
+			"count": 1,
+			"page":  2,
+		})
+	})
+
+	created := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	a, b := primitive.NewObjectID(), primitive.NewObjectID()
+
+	page, err := c.List(context.Background(), ListOpts{
+		Filters: []Filter{
+			{Field: "text", Value: "ada"},
+			{Field: "created", Op: "gte", Value: created},
+			{Field: "id", Op: "in", Value: []any{a, b}},
+			{Field: "tags", Op: "nin", Value: []string{"x", "y"}},
+			{Field: "count", Op: "eq", Value: 3},
+		},
+		Sort:  []string{"-created", "text"},
+		Limit: 5,
+		Page:  2,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/api/notes/" {
+		t.Fatalf("requested %s", path)
+	}
+
+	expected := url.Values{
+		"text":         {"ada"},
+		"created[gte]": {"2025-03-01T12:00:00Z"},
+		"id[in]":       {a.Hex() + "," + b.Hex()},
+		"tags[nin]":    {"x,y"},
+		"count":        {"3"},
+		"sort":         {"-created,text"},
+		"limit":        {"5"},
+		"page":         {"2"},
+	}
+
+	if query.Encode() != expected.Encode() {
+		t.Fatalf("query %s, expected %s", query.Encode(), expected.Encode())
+	}
+
+	if len(page.Documents) != 1 || page.Documents[0].Data.Text != "ada" || page.Count != 1 || page.Page != 2 {
+		t.Fatalf("unexpected page %+v", page)
+	}
+
+	t.Run("defaults are left to the server", func(t *testing.T) {
+		if _, err := c.List(context.Background(), ListOpts{}); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(query) != 0 {
+			t.Fatalf("sent %s", query.Encode())
+		}
+	})
+}
+
+func TestListEmpty(t *testing.T) {
+	message := "no data"
+
+	c := serve(t, func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.WriteHeader(nethttp.StatusBadRequest)
+		json.NewEncoder(w).Encode(http.Response{Error: message})
+	})
+
+	tests := []struct {
+		name string
+		page int
+		want int
+	}{
+		{"first page", 0, 1},
+		{"later page", 3, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := c.List(context.Background(), ListOpts{Page: tt.page})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(page.Documents) != 0 || page.Page != tt.want {
+				t.Fatalf("unexpected page %+v", page)
+			}
+		})
+	}
+
+	t.Run("other bad requests", func(t *testing.T) {
+		message = "invalid filter"
+
+		var badRequest http.ErrBadRequest
+
+		if _, err := c.List(context.Background(), ListOpts{}); !errors.As(err, &badRequest) || badRequest.Message != message {
+			t.Fatalf("expected the bad request, got %v", err)
+		}
+	})
+}
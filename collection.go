@@ -3,16 +3,24 @@ package scaffold
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
 	"strconv"
 	"time"
 
+	"github.com/alexsobiek/scaffold/auth"
 	"github.com/alexsobiek/scaffold/http"
+	"github.com/alexsobiek/scaffold/metrics"
+	"github.com/alexsobiek/scaffold/openapi"
 	"github.com/alexsobiek/scaffold/query"
+	"github.com/alexsobiek/scaffold/ratelimit"
+	"github.com/alexsobiek/scaffold/tenant"
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // AccessFn is a callback function which is called before a document is accessed from the database.
@@ -34,37 +42,77 @@ type DeleteFn[T any] func(context.Context, primitive.ObjectID) error
 type Collection interface {
 	Name() string
 	Slug() string
-	inject(*mongo.Collection, *gin.RouterGroup)
+	inject(context.Context, *Scaffold, *gin.RouterGroup) error
+	describe(*openapi.Spec, bool)
+	graphql(*graphQLSchema)
+	dataType() reflect.Type
+	limits() map[auth.Operation]ratelimit.Limit
 }
 
 type CollectionOpts[T any] struct {
-	Name       string
-	Slug       string
-	Defaults   []Document[T]
-	Access     AccessFn[T]
-	Read       ReadFn[T]
-	Write      WriteFn[T]
-	Update     UpdateFn[T]
+	Name     string
+	Slug     string
+	Defaults []Document[T]
+	Access   AccessFn[T]
+	Read     ReadFn[T]
+	Write    WriteFn[T]
+	Update   UpdateFn[T]
+	// Delete is called before a document is deleted. Documents can only be deleted through the
+	// API when Delete, Access or Permissions is set.
 	Delete     DeleteFn[T]
 	Middleware []gin.HandlerFunc
 	Routes     []gin.RouteInfo
+	// Upcasters upgrade documents written with older shapes of T when they are read, so old and
+	// new shapes can coexist without bulk migrations. Upcasters[i] converts a document at schema
+	// version i to version i+1, and new documents are written at version len(Upcasters).
+	// Documents without a version are at version 0.
+	Upcasters []Upcaster
+	// WriteBack stores documents once they are upcast, so each is only upcast once.
+	WriteBack bool
+	// Permissions maps roles to the operations they may perform through the REST API. When set,
+	// requests whose principal holds none of the required roles are rejected.
+	Permissions auth.Permissions
+	// RateLimits limits how often each client may perform an operation. A limit for auth.OpAll
+	// applies to every operation.
+	RateLimits map[auth.Operation]ratelimit.Limit
+	// Filterable lists the JSON names of the top-level fields of T clients may filter and sort
+	// lists on. id, created and last_updated always are, other fields are not unless listed so
+	// fields hidden by Read hooks cannot be probed with filters.
+	Filterable []string
 }
 
 type C[T any] struct {
-	name       string
-	slug       string
-	defaults   []Document[T]
-	mc         *mongo.Collection
-	access     AccessFn[T]
-	read       ReadFn[T]
-	write      WriteFn[T]
-	update     UpdateFn[T]
-	delete     DeleteFn[T]
-	middleware []gin.HandlerFunc
-	routes     []gin.RouteInfo
+	name        string
+	slug        string
+	defaults    []Document[T]
+	mc          *mongo.Collection
+	db          *Database
+	tenancy     *TenancyOpts
+	access      AccessFn[T]
+	read        ReadFn[T]
+	write       WriteFn[T]
+	update      UpdateFn[T]
+	delete      DeleteFn[T]
+	middleware  []gin.HandlerFunc
+	routes      []gin.RouteInfo
+	permissions auth.Permissions
+	rateLimits  map[auth.Operation]ratelimit.Limit
+	limiter     *ratelimit.Limiter
+	metrics     *metrics.Metrics
+	tracer      trace.Tracer
+	fields      query.Fields
+	filterable  []string
+	upcasters   []Upcaster
+	writeBack   bool
+	deletable   bool
+	// instrumented is set once the hooks are wrapped by instrument
+	instrumented bool
+	stop         <-chan struct{}
 }
 
 func NewCollection[T any](opts CollectionOpts[T]) *C[T] {
+	deletable := opts.Delete != nil || opts.Access != nil || opts.Permissions != nil
+
 	if opts.Access == nil {
 		opts.Access = func(_ context.Context, id primitive.ObjectID) error {
 			return nil
@@ -96,16 +144,23 @@ func NewCollection[T any](opts CollectionOpts[T]) *C[T] {
 	}
 
 	return &C[T]{
-		name:       opts.Name,
-		slug:       opts.Slug,
-		defaults:   opts.Defaults,
-		access:     opts.Access,
-		read:       opts.Read,
-		write:      opts.Write,
-		update:     opts.Update,
-		delete:     opts.Delete,
-		middleware: opts.Middleware,
-		routes:     opts.Routes,
+		name:        opts.Name,
+		slug:        opts.Slug,
+		defaults:    opts.Defaults,
+		access:      opts.Access,
+		read:        opts.Read,
+		write:       opts.Write,
+		update:      opts.Update,
+		delete:      opts.Delete,
+		middleware:  opts.Middleware,
+		routes:      opts.Routes,
+		permissions: opts.Permissions,
+		rateLimits:  opts.RateLimits,
+		fields:      queryFields(reflect.TypeOf((*T)(nil)).Elem(), opts.Filterable),
+		filterable:  opts.Filterable,
+		upcasters:   opts.Upcasters,
+		writeBack:   opts.WriteBack,
+		deletable:   deletable,
 	}
 }
 
@@ -117,17 +172,35 @@ func (c C[T]) Slug() string {
 	return c.slug
 }
 
-func (c *C[T]) inject(mc *mongo.Collection, rg *gin.RouterGroup) {
-	c.mc = mc
+func (c *C[T]) limits() map[auth.Operation]ratelimit.Limit {
+	return c.rateLimits
+}
+
+func (c *C[T]) inject(ctx context.Context, s *Scaffold, rg *gin.RouterGroup) error {
+	c.db = s.db
+	c.mc = s.db.Collection(c.slug)
+	c.tenancy = s.opts.Tenancy
+	c.limiter = s.limiter
+	c.metrics = s.metrics
+	c.tracer = s.tracer
+	c.stop = s.stop
+
+	c.instrument()
+
+	for _, name := range c.filterable {
+		if _, ok := c.fields[name]; !ok {
+			return fmt.Errorf("collection %s: cannot filter on unknown field %q", c.slug, name)
+		}
+	}
 
 	for i := range c.defaults {
 		doc := c.defaults[i]
 
-		_, err := c.FindById(Context, doc.ID)
+		_, err := c.FindById(ctx, doc.ID)
 
 		if err != nil {
 			if err != mongo.ErrNoDocuments {
-				panic(err)
+				return fmt.Errorf("collection %s: seeding default %s: %w", c.slug, doc.ID.Hex(), err)
 			}
 		} else {
 			continue
@@ -143,10 +216,14 @@ func (c *C[T]) inject(mc *mongo.Collection, rg *gin.RouterGroup) {
 			doc.LastUpdated = now
 		}
 
-		_, err = c.mc.InsertOne(Context, doc)
+		if doc.SchemaVersion == 0 {
+			doc.SchemaVersion = c.schemaVersion()
+		}
+
+		_, err = c.mc.InsertOne(ctx, doc)
 
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("collection %s: seeding default %s: %w", c.slug, doc.ID.Hex(), err)
 		}
 	}
 
@@ -157,15 +234,48 @@ func (c *C[T]) inject(mc *mongo.Collection, rg *gin.RouterGroup) {
 		rg.Match([]string{route.Method}, route.Path, route.HandlerFunc)
 	}
 
-	rg.POST("/", c.handlePost)
-	rg.GET("/", c.handleGet)
-	rg.GET("/:id", c.handleGetById)
-	rg.PATCH("/:id", c.handlePatch)
+	rg.POST("/", c.handlers(auth.OpCreate, c.handlePost)...)
+	rg.GET("/", c.handlers(auth.OpList, c.handleGet)...)
+	rg.GET("/_changes", c.handlers(auth.OpList, c.handleChanges)...)
+	rg.GET("/:id", c.handlers(auth.OpRead, c.handleGetById)...)
+	rg.PATCH("/:id", c.handlers(auth.OpUpdate, c.handlePatch)...)
+
+	if c.deletable {
+		rg.DELETE("/:id", c.handlers(auth.OpDelete, c.handleDelete)...)
+	}
+
+	return nil
+}
+
+// handlers prepends metric labelling and the rate limiters configured for op to the handler of
+// a route.
+func (c *C[T]) handlers(op auth.Operation, h gin.HandlerFunc) []gin.HandlerFunc {
+	handlers := []gin.HandlerFunc{
+		func(ctx *gin.Context) {
+			metrics.SetLabels(ctx, c.slug, string(op))
+		},
+	}
+
+	if c.limiter != nil {
+		if l, ok := c.rateLimits[auth.OpAll]; ok {
+			handlers = append(handlers, c.limiter.Middleware(c.slug, l))
+		}
+
+		if l, ok := c.rateLimits[op]; ok && op != auth.OpAll {
+			handlers = append(handlers, c.limiter.Middleware(c.slug+":"+string(op), l))
+		}
+	}
+
+	return append(handlers, h)
 }
 
 func (c *C[T]) Insert(ctx context.Context, data T) (*Document[T], error) {
 	doc := createDocument(c, data)
 
+	if id, ok := tenant.From(ctx); ok {
+		doc.TenantID = id
+	}
+
 	d, err := c.write(ctx, doc.ID, doc.Data)
 
 	if err != nil {
@@ -174,7 +284,9 @@ func (c *C[T]) Insert(ctx context.Context, data T) (*Document[T], error) {
 
 	doc.Data = d
 
-	_, err = c.mc.InsertOne(ctx, doc)
+	opCtx, done := c.op(ctx, "insert_one")
+	_, err = c.collection(ctx).InsertOne(opCtx, doc, &options.InsertOneOptions{Comment: comment(ctx)})
+	done(err)
 
 	if err != nil {
 		return nil, err
@@ -190,16 +302,83 @@ func (c *C[T]) Insert(ctx context.Context, data T) (*Document[T], error) {
 	return doc, nil
 }
 
+// InsertMany inserts documents in a single operation, calling the write and read hooks for each.
+// Documents are inserted in order, stopping at the first which fails; those stored before it are
+// returned along with the error. Use Database.Transaction to insert all or none.
+func (c *C[T]) InsertMany(ctx context.Context, data []T) ([]*Document[T], error) {
+	docs := make([]*Document[T], len(data))
+	insert := make([]any, len(data))
+
+	for i := range data {
+		doc := createDocument(c, data[i])
+
+		if id, ok := tenant.From(ctx); ok {
+			doc.TenantID = id
+		}
+
+		d, err := c.write(ctx, doc.ID, doc.Data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		doc.Data = d
+		docs[i] = doc
+		insert[i] = doc
+	}
+
+	if len(insert) == 0 {
+		return docs, nil
+	}
+
+	opCtx, done := c.op(ctx, "insert_many")
+	_, insertErr := c.collection(ctx).InsertMany(opCtx, insert, &options.InsertManyOptions{Comment: comment(ctx)})
+	done(insertErr)
+
+	if insertErr != nil {
+		var bwe mongo.BulkWriteException
+
+		if !errors.As(insertErr, &bwe) || len(bwe.WriteErrors) == 0 {
+			return nil, insertErr
+		}
+
+		// The insert is ordered, so the documents before the one which failed were stored
+		docs = docs[:bwe.WriteErrors[0].Index]
+	}
+
+	for _, doc := range docs {
+		d, err := c.read(ctx, doc.ID, doc.Data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		doc.Data = d
+	}
+
+	return docs, insertErr
+}
+
 func (c *C[T]) Find(ctx context.Context, query query.Query) (*Document[T], error) {
-	var doc *Document[T]
+	opts := options.FindOne()
+
+	if cm, ok := comment(ctx).(string); ok {
+		opts.SetComment(cm)
+	}
 
-	err := c.mc.FindOne(ctx, query.Filter()).Decode(&doc)
+	opCtx, done := c.op(ctx, "find_one")
+	raw, err := c.collection(ctx).FindOne(opCtx, c.filter(ctx, query.Filter()), opts).Raw()
+	done(err)
 
 	if err != nil {
 		return nil, err
 	}
 
-	doc.collection = c
+	doc, err := c.decode(ctx, raw)
+
+	if err != nil {
+		return nil, err
+	}
 
 	d, err := c.read(ctx, doc.ID, doc.Data)
 
@@ -220,7 +399,7 @@ func (c *C[T]) FindById(ctx context.Context, id primitive.ObjectID) (*Document[T
 	return c.Find(ctx, query.ID(id))
 }
 
-func (c *C[T]) FindMany(ctx context.Context, query query.Query, limit int, page int) ([]Document[T], error) {
+func (c *C[T]) FindMany(ctx context.Context, q query.Query, limit int, page int) ([]Document[T], error) {
 	var docs []Document[T]
 
 	lim := int64(limit)
@@ -230,7 +409,17 @@ func (c *C[T]) FindMany(ctx context.Context, query query.Query, limit int, page
 		Skip:  &skip,
 	}
 
-	cur, err := c.mc.Find(ctx, query.Filter(), opts)
+	if cm, ok := comment(ctx).(string); ok {
+		opts.SetComment(cm)
+	}
+
+	if s, ok := q.(query.Sorter); ok {
+		opts.SetSort(s.Sorting())
+	}
+
+	opCtx, done := c.op(ctx, "find")
+	cur, err := c.collection(ctx).Find(opCtx, c.filter(ctx, q.Filter()), opts)
+	done(err)
 
 	if err != nil {
 		return nil, err
@@ -239,9 +428,7 @@ func (c *C[T]) FindMany(ctx context.Context, query query.Query, limit int, page
 	defer cur.Close(ctx)
 
 	for cur.Next(ctx) {
-		var doc Document[T]
-
-		err := cur.Decode(&doc)
+		doc, err := c.decode(ctx, cur.Current)
 
 		if err != nil {
 			return nil, err
@@ -254,8 +441,6 @@ func (c *C[T]) FindMany(ctx context.Context, query query.Query, limit int, page
 			continue
 		}
 
-		doc.collection = c
-
 		d, err := c.read(ctx, doc.ID, doc.Data)
 
 		if err != nil {
@@ -264,12 +449,52 @@ func (c *C[T]) FindMany(ctx context.Context, query query.Query, limit int, page
 
 		doc.Data = d
 
-		docs = append(docs, doc)
+		docs = append(docs, *doc)
 	}
 	return docs, nil
 }
 
+// comment tags Mongo commands with the ID of the request they were issued for, so they can be
+// correlated with slow query logs.
+func comment(ctx context.Context) any {
+	if id := http.RequestID(ctx); id != "" {
+		return "request_id=" + id
+	}
+
+	return nil
+}
+
+// authorize checks the request principal may perform op on this collection, writing an error
+// response when it may not.
+func (c *C[T]) authorize(ctx *gin.Context, op auth.Operation) bool {
+	if err := c.allowed(ctx, op); err != nil {
+		http.Error(ctx, err)
+		return false
+	}
+
+	return true
+}
+
+// allowed checks the principal of ctx may perform op on this collection.
+func (c *C[T]) allowed(ctx context.Context, op auth.Operation) error {
+	p, ok := auth.FromContext(ctx)
+
+	if c.permissions != nil && !ok {
+		return http.ErrUnauthorized{Message: "unauthorized"}
+	}
+
+	if !p.Allows(c.slug, op) || (c.permissions != nil && !c.permissions.Allows(p, op)) {
+		return http.ErrForbidden{Message: "forbidden"}
+	}
+
+	return nil
+}
+
 func (c *C[T]) handleGet(ctx *gin.Context) {
+	if !c.authorize(ctx, auth.OpList) {
+		return
+	}
+
 	var err error
 	limit := 10
 	page := 1
@@ -297,9 +522,14 @@ func (c *C[T]) handleGet(ctx *gin.Context) {
 		}
 	}
 
-	query := query.Empty() // TODO: Implement query parsing
+	q, err := c.fields.Parse(ctx.Request.URL.Query())
+
+	if err != nil {
+		http.BadRequest(ctx, err)
+		return
+	}
 
-	docs, err := c.FindMany(Context, query, limit, page)
+	docs, err := c.FindMany(ctx, q, limit, page)
 
 	if err != nil {
 		http.Error(ctx, err)
@@ -310,6 +540,10 @@ func (c *C[T]) handleGet(ctx *gin.Context) {
 }
 
 func (c *C[T]) handleGetById(ctx *gin.Context) {
+	if !c.authorize(ctx, auth.OpRead) {
+		return
+	}
+
 	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
 
 	if err != nil {
@@ -332,6 +566,10 @@ func (c *C[T]) handleGetById(ctx *gin.Context) {
 }
 
 func (c *C[T]) handlePost(ctx *gin.Context) {
+	if !c.authorize(ctx, auth.OpCreate) {
+		return
+	}
+
 	// Ensure Content-Type is application/json
 	if ctx.GetHeader("Content-Type") != "application/json" {
 		http.BadRequest(ctx, nil)
@@ -364,6 +602,10 @@ func (c *C[T]) handlePost(ctx *gin.Context) {
 }
 
 func (c *C[T]) handlePatch(ctx *gin.Context) {
+	if !c.authorize(ctx, auth.OpUpdate) {
+		return
+	}
+
 	// Ensure Content-Type is application/json
 	if ctx.GetHeader("Content-Type") != "application/json" {
 		http.BadRequest(ctx, nil)
@@ -411,3 +653,34 @@ func (c *C[T]) handlePatch(ctx *gin.Context) {
 
 	http.Ok(ctx, doc)
 }
+
+func (c *C[T]) handleDelete(ctx *gin.Context) {
+	if !c.authorize(ctx, auth.OpDelete) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(ctx.Param("id"))
+
+	if err != nil {
+		http.BadRequest(ctx, errors.New("invalid id"))
+		return
+	}
+
+	doc, err := c.FindById(ctx, id)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.NotFound(ctx, nil)
+		} else {
+			http.Error(ctx, err)
+		}
+		return
+	}
+
+	if err := doc.Delete(ctx); err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	http.Ok(ctx, doc)
+}
@@ -0,0 +1,199 @@
+package scaffold
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	nethttp "net/http"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestDeleteRoute(t *testing.T) {
+	guard := func(context.Context, primitive.ObjectID) error { return nil }
+
+	open := NewCollection(CollectionOpts[note]{Name: "Open", Slug: "open"})
+	deleteHook := NewCollection(CollectionOpts[note]{Name: "Delete hook", Slug: "delete-hook", Delete: guard})
+	accessHook := NewCollection(CollectionOpts[note]{Name: "Access hook", Slug: "access-hook", Access: guard})
+	permissions := NewCollection(CollectionOpts[note]{
+		Name:        "Permissions",
+		Slug:        "permissions",
+		Permissions: auth.Permissions{"admin": {auth.OpAll}},
+	})
+
+	_, h := testScaffold(t, ScaffoldOpts{Collections: []Collection{open, deleteHook, accessHook, permissions}})
+
+	tests := []struct {
+		c      *C[note]
+		status int
+	}{
+		{open, nethttp.StatusNotFound},
+		{deleteHook, nethttp.StatusOK},
+		{accessHook, nethttp.StatusOK},
+		{permissions, nethttp.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.c.slug, func(t *testing.T) {
+			doc, err := tt.c.Insert(context.Background(), note{Text: "keep"})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expectStatus(t, request(h, "DELETE", "/"+tt.c.slug+"/"+doc.ID.Hex(), nil), tt.status)
+		})
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	id := primitive.NewObjectID()
+	notes := NewCollection(CollectionOpts[note]{
+		Name:     "Notes",
+		Slug:     "notes",
+		Defaults: []Document[note]{{ID: id, Data: &note{Text: "welcome"}}},
+	})
+
+	_, h := testScaffold(t, ScaffoldOpts{Collections: []Collection{notes}})
+
+	expectStatus(t, request(h, "GET", "/notes/"+id.Hex(), nil), nethttp.StatusOK)
+
+	t.Run("seeding errors fail setup", func(t *testing.T) {
+		uri := os.Getenv("MONGO_URI")
+		denied := NewCollection(CollectionOpts[note]{
+			Name:     "Denied",
+			Slug:     "denied",
+			Defaults: []Document[note]{{ID: id, Data: &note{Text: "welcome"}}},
+			Access:   func(context.Context, primitive.ObjectID) error { return errors.New("denied") },
+		})
+
+		s := New(ScaffoldOpts{MongoURI: uri, Database: "scaffold_test_defaults", Collections: []Collection{denied}})
+
+		if _, err := s.Handler(context.Background()); err == nil || !strings.Contains(err.Error(), "denied") {
+			t.Fatalf("expected the seeding error, got %v", err)
+		}
+
+		s.Shutdown(context.Background())
+	})
+}
+
+type profile struct {
+	Name   string `bson:"name" json:"name"`
+	Salary int    `bson:"salary" json:"salary"`
+}
+
+func TestFilterable(t *testing.T) {
+	profiles := NewCollection(CollectionOpts[profile]{
+		Name:       "Profiles",
+		Slug:       "profiles",
+		Filterable: []string{"name"},
+		// Salaries are hidden from clients
+		Read: func(_ context.Context, _ primitive.ObjectID, data *profile) (*profile, error) {
+			data.Salary = 0
+			return data, nil
+		},
+	})
+
+	_, h := testScaffold(t, ScaffoldOpts{Collections: []Collection{profiles}})
+
+	for _, p := range []profile{{"ada", 100}, {"grace", 200}} {
+		if _, err := profiles.Insert(context.Background(), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(t *testing.T, query string) []string {
+		t.Helper()
+
+		w := request(h, "GET", "/profiles/?"+query, nil)
+		expectStatus(t, w, nethttp.StatusOK)
+
+		var res struct {
+			Data []Document[profile] `json:"data"`
+		}
+
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+
+		for _, d := range res.Data {
+			names = append(names, d.Data.Name)
+		}
+
+		sort.Strings(names)
+
+		return names
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		names string
+	}{
+		{"filterable field", "name=grace", "grace"},
+		{"unknown parameter", "_=1700000000", "ada,grace"},
+		{"field which is not filterable", "salary[gt]=150", "ada,grace"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if names := strings.Join(list(t, tt.query), ","); names != tt.names {
+				t.Fatalf("listed %s", names)
+			}
+		})
+	}
+
+	expectStatus(t, request(h, "GET", "/profiles/?sort=-salary", nil), nethttp.StatusBadRequest)
+	expectStatus(t, request(h, "GET", "/profiles/?name[regex]=a", nil), nethttp.StatusBadRequest)
+
+	t.Run("unknown filterable field", func(t *testing.T) {
+		c := NewCollection(CollectionOpts[profile]{Name: "Profiles", Slug: "profiles", Filterable: []string{"salry"}})
+		s := New(ScaffoldOpts{MongoURI: os.Getenv("MONGO_URI"), Database: "scaffold_test_filterable", Collections: []Collection{c}})
+
+		if _, err := s.Handler(context.Background()); err == nil || !strings.Contains(err.Error(), "salry") {
+			t.Fatalf("expected unknown field to be rejected, got %v", err)
+		}
+
+		s.Shutdown(context.Background())
+	})
+}
+
+func TestInsertMany(t *testing.T) {
+	notes := NewCollection(CollectionOpts[note]{Name: "Notes", Slug: "notes"})
+	s, _ := testScaffold(t, ScaffoldOpts{Collections: []Collection{notes}})
+
+	ctx := context.Background()
+
+	_, err := s.Database().Collection("notes").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"text": 1},
+		Options: options.Index().SetUnique(true),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := notes.InsertMany(ctx, []note{{"a"}, {"b"}, {"a"}, {"c"}})
+
+	if !mongo.IsDuplicateKeyError(err) {
+		t.Fatalf("expected a duplicate key error, got %v", err)
+	}
+
+	if len(docs) != 2 || docs[0].Data.Text != "a" || docs[1].Data.Text != "b" {
+		t.Fatalf("expected the documents inserted before the failure, got %v", docs)
+	}
+
+	if n, err := s.Database().Collection("notes").CountDocuments(ctx, bson.M{}); err != nil || n != 2 {
+		t.Fatalf("stored %d documents, %v", n, err)
+	}
+}
@@ -3,6 +3,7 @@ package scaffold
 import (
 	"context"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -11,10 +12,12 @@ type Database struct {
 	ctx    context.Context
 	client *mongo.Client
 	db     *mongo.Database
+	name   string
 }
 
-func NewDatabase(ctx context.Context, mongoUri string, database string) (*Database, error) {
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoUri))
+// NewDatabase connects to Mongo. Additional client options are applied after the URI.
+func NewDatabase(ctx context.Context, mongoUri string, database string, opts ...*options.ClientOptions) (*Database, error) {
+	client, err := mongo.Connect(ctx, append([]*options.ClientOptions{options.Client().ApplyURI(mongoUri)}, opts...)...)
 
 	if err != nil {
 		return nil, err
@@ -31,6 +34,7 @@ func NewDatabase(ctx context.Context, mongoUri string, database string) (*Databa
 		ctx:    ctx,
 		client: client,
 		db:     db,
+		name:   database,
 	}, nil
 }
 
@@ -38,8 +42,46 @@ func (d *Database) Collection(name string) *mongo.Collection {
 	return d.db.Collection(name)
 }
 
-func (d *Database) Close() {
-	if err := d.client.Disconnect(d.ctx); err != nil {
-		panic(err)
+// CollectionNames lists the collections in the database.
+func (d *Database) CollectionNames(ctx context.Context) ([]string, error) {
+	return d.db.ListCollectionNames(ctx, bson.M{})
+}
+
+// Tenant returns the database holding the documents of a tenant when database-per-tenant
+// tenancy is enabled.
+func (d *Database) Tenant(id string) *mongo.Database {
+	return d.client.Database(d.name + "_" + id)
+}
+
+func (d *Database) TenantCollection(id string, name string) *mongo.Collection {
+	return d.Tenant(id).Collection(name)
+}
+
+// Transaction runs fn in a multi-document transaction, committing it when fn succeeds and
+// aborting it otherwise. Collection methods and their hooks called with the context passed to fn
+// take part in the transaction. Transient errors retry the whole transaction, so fn may run more
+// than once. Calls within a transaction in progress join it. Transactions require a replica set.
+func (d *Database) Transaction(ctx context.Context, fn func(context.Context) error, opts ...*options.TransactionOptions) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
 	}
+
+	sess, err := d.client.StartSession()
+
+	if err != nil {
+		return err
+	}
+
+	defer sess.EndSession(context.WithoutCancel(ctx))
+
+	_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, fn(sc)
+	}, opts...)
+
+	return err
+}
+
+// Close disconnects from Mongo, waiting for in-use connections to be returned until ctx is done.
+func (d *Database) Close(ctx context.Context) error {
+	return d.client.Disconnect(ctx)
 }
@@ -0,0 +1,135 @@
+package scaffold
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestTransaction(t *testing.T) {
+	notes := NewCollection(CollectionOpts[note]{Name: "Notes", Slug: "notes"})
+	profiles := NewCollection(CollectionOpts[profile]{Name: "Profiles", Slug: "profiles"})
+
+	s, _ := testScaffold(t, ScaffoldOpts{Collections: []Collection{notes, profiles}})
+
+	ctx := context.Background()
+
+	// Transactions need a replica set
+	err := s.Transaction(ctx, func(ctx context.Context) error {
+		_, err := s.Database().Collection("_transactions").InsertOne(ctx, bson.M{})
+		return err
+	})
+
+	if err != nil {
+		t.Skipf("transactions are unavailable: %v", err)
+	}
+
+	ada, err := profiles.Insert(ctx, profile{Name: "ada", Salary: 100})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(t *testing.T) int64 {
+		t.Helper()
+
+		n, err := notes.mc.CountDocuments(ctx, bson.M{})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return n
+	}
+
+	abort := errors.New("abort")
+
+	t.Run("error rolls back", func(t *testing.T) {
+		err := s.Transaction(ctx, func(ctx context.Context) error {
+			if _, err := notes.Insert(ctx, note{Text: "rolled back"}); err != nil {
+				return err
+			}
+
+			doc, err := profiles.FindById(ctx, ada.ID)
+
+			if err != nil {
+				return err
+			}
+
+			if err := doc.Set(ctx, "salary", 200); err != nil {
+				return err
+			}
+
+			return abort
+		})
+
+		if !errors.Is(err, abort) {
+			t.Fatalf("expected the error of fn, got %v", err)
+		}
+
+		if n := count(t); n != 0 {
+			t.Fatalf("insert was not rolled back, %d notes stored", n)
+		}
+
+		doc, err := profiles.FindById(ctx, ada.ID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if doc.Data.Salary != 100 {
+			t.Fatalf("update was not rolled back, salary is %d", doc.Data.Salary)
+		}
+	})
+
+	t.Run("nested transactions join", func(t *testing.T) {
+		err := s.Transaction(ctx, func(outer context.Context) error {
+			if _, err := notes.Insert(outer, note{Text: "outer"}); err != nil {
+				return err
+			}
+
+			err := s.Transaction(outer, func(inner context.Context) error {
+				if mongo.SessionFromContext(inner) != mongo.SessionFromContext(outer) {
+					return errors.New("nested transaction started a session")
+				}
+
+				_, err := notes.Insert(inner, note{Text: "inner"})
+
+				return err
+			})
+
+			if err != nil {
+				return err
+			}
+
+			return abort
+		})
+
+		if !errors.Is(err, abort) {
+			t.Fatalf("expected the error of fn, got %v", err)
+		}
+
+		// Committing the nested transaction did not commit the outer one
+		if n := count(t); n != 0 {
+			t.Fatalf("%d notes stored after the outer transaction aborted", n)
+		}
+	})
+
+	t.Run("commit", func(t *testing.T) {
+		err := s.Transaction(ctx, func(ctx context.Context) error {
+			_, err := notes.Insert(ctx, note{Text: "committed"})
+			return err
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := count(t); n != 1 {
+			t.Fatalf("%d notes stored after committing", n)
+		}
+	})
+}
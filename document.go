@@ -8,24 +8,34 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Document[T any] struct {
 	ID          primitive.ObjectID `bson:"_id" json:"id"`
 	Created     primitive.DateTime `bson:"created" json:"created"`
 	LastUpdated primitive.DateTime `bson:"last_updated" json:"last_updated"`
-	Data        *T                 `bson:",inline" json:"document"`
-	collection  *C[T]              `bson:"-"`
+	TenantID    string             `bson:"tenant_id,omitempty" json:"-"`
+	// SchemaVersion is the version of T the document was written with, see
+	// CollectionOpts.Upcasters.
+	SchemaVersion int   `bson:"schema_version,omitempty" json:"-"`
+	Data          *T    `bson:",inline" json:"document"`
+	collection    *C[T] `bson:"-"`
+	// stored is the schema version of the document in the database, older than SchemaVersion
+	// when it was upcast on read and not written back.
+	stored int
 }
 
 func createDocument[T any](collection *C[T], data T) *Document[T] {
 	now := primitive.DateTime(time.Now().UnixNano() / int64(time.Millisecond))
 	return &Document[T]{
-		ID:          primitive.NewObjectID(),
-		Created:     now,
-		LastUpdated: now,
-		Data:        &data,
-		collection:  collection,
+		ID:            primitive.NewObjectID(),
+		Created:       now,
+		LastUpdated:   now,
+		SchemaVersion: collection.schemaVersion(),
+		Data:          &data,
+		collection:    collection,
+		stored:        collection.schemaVersion(),
 	}
 }
 
@@ -96,7 +106,14 @@ func (d *Document[T]) SetMany(ctx context.Context, fields map[string]any) error
 			field.Set(reflect.ValueOf(value))
 
 			// Add the changed field to the map with bson field name
-			dbUpdates[names.BsonField] = value
+			if structField.Anonymous && inline(structField) {
+				setInline(dbUpdates, field)
+			} else if structField.Anonymous {
+				// Embedded structs are stored as a subdocument named after the lowercased type
+				dbUpdates[bsonName(structField)] = value
+			} else {
+				dbUpdates[names.BsonField] = value
+			}
 		}
 	}
 
@@ -109,14 +126,29 @@ func (d *Document[T]) SetMany(ctx context.Context, fields map[string]any) error
 			return err
 		}
 
-		_, err = d.collection.mc.UpdateByID(ctx, d.ID, bson.M{"$set": dbUpdates})
+		// Documents upcast on read are stored whole so fields which were not updated are
+		// upgraded too
+		if d.stored < d.SchemaVersion {
+			return d.upgrade(ctx, *dbUpdates)
+		}
 
-		return err
+		return d.set(ctx, *dbUpdates)
 	}
 
 	return nil
 }
 
+// set stores updated fields.
+func (d *Document[T]) set(ctx context.Context, updates bson.M) error {
+	c := d.collection
+
+	opCtx, done := c.op(ctx, "update_one")
+	_, err := c.collection(ctx).UpdateOne(opCtx, c.filter(ctx, bson.M{"_id": d.ID}), bson.M{"$set": updates}, &options.UpdateOptions{Comment: comment(ctx)})
+	done(err)
+
+	return err
+}
+
 func (d *Document[T]) Delete(ctx context.Context) error {
 
 	err := d.collection.delete(ctx, d.ID)
@@ -125,6 +157,9 @@ func (d *Document[T]) Delete(ctx context.Context) error {
 		return err
 	}
 
-	_, err = d.collection.mc.DeleteOne(ctx, bson.M{"_id": d.ID})
+	c := d.collection
+	opCtx, done := c.op(ctx, "delete_one")
+	_, err = c.collection(ctx).DeleteOne(opCtx, c.filter(ctx, bson.M{"_id": d.ID}), &options.DeleteOptions{Comment: comment(ctx)})
+	done(err)
 	return err
 }
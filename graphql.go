@@ -0,0 +1,988 @@
+package scaffold
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/alexsobiek/scaffold/query"
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type GraphQLOpts struct {
+	// Path is where queries are accepted as POST requests, defaults to "/graphql".
+	Path string
+	// MaxDepth rejects queries nesting fields deeper than it, defaults to 10. Introspection
+	// fields are not counted.
+	MaxDepth int
+}
+
+var (
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	dateTimeType = reflect.TypeOf(primitive.DateTime(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// graphQLSchema collects the types and fields contributed by each collection.
+type graphQLSchema struct {
+	queries   graphql.Fields
+	mutations graphql.Fields
+	outputs   map[reflect.Type]graphql.Output
+	inputs    map[reflect.Type]graphql.Input
+	typeNames map[reflect.Type]string
+	names     map[string]bool
+	documents map[string]*graphQLRef
+	json      *graphql.Scalar
+	long      *graphql.Scalar
+	filter    *graphql.InputObject
+}
+
+// graphQLRef resolves documents of a collection referenced by the `ref:"<slug>"` tag of an
+// ObjectID field in another.
+type graphQLRef struct {
+	typ  *graphql.Object
+	find func(context.Context, primitive.ObjectID) (any, error)
+}
+
+func newGraphQLSchema(collections []Collection) (graphql.Schema, error) {
+	if len(collections) == 0 {
+		return graphql.Schema{}, errors.New("GraphQL requires at least one collection")
+	}
+
+	g := &graphQLSchema{
+		queries:   graphql.Fields{},
+		mutations: graphql.Fields{},
+		outputs:   map[reflect.Type]graphql.Output{},
+		inputs:    map[reflect.Type]graphql.Input{},
+		typeNames: map[reflect.Type]string{},
+		names:     map[string]bool{"JSON": true, "Long": true, "Filter": true, "FilterOperator": true},
+		documents: map[string]*graphQLRef{},
+	}
+
+	g.json = graphql.NewScalar(graphql.ScalarConfig{
+		Name:         "JSON",
+		Description:  "Any JSON value",
+		Serialize:    func(v any) any { return v },
+		ParseValue:   func(v any) any { return v },
+		ParseLiteral: parseJSONLiteral,
+	})
+
+	// Int is 32-bit, wider integers are exposed as Long so large values are not lost
+	g.long = graphql.NewScalar(graphql.ScalarConfig{
+		Name:         "Long",
+		Description:  "A 64-bit integer",
+		Serialize:    func(v any) any { return v },
+		ParseValue:   parseLong,
+		ParseLiteral: parseLongLiteral,
+	})
+
+	var ops []string
+
+	for op := range query.Operators {
+		ops = append(ops, op)
+	}
+
+	sort.Strings(ops)
+
+	operators := graphql.EnumValueConfigMap{}
+
+	for _, op := range ops {
+		operators[op] = &graphql.EnumValueConfig{Value: op}
+	}
+
+	g.filter = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "Filter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"field": {Type: graphql.NewNonNull(graphql.String)},
+			"op": {
+				Type:         graphql.NewEnum(graphql.EnumConfig{Name: "FilterOperator", Values: operators}),
+				DefaultValue: "eq",
+			},
+			"value": {
+				Type:        graphql.NewNonNull(graphql.String),
+				Description: "Converted to the type of the field, comma separated for in and nin",
+			},
+		},
+	})
+
+	for _, c := range collections {
+		c.graphql(g)
+	}
+
+	if len(g.queries) == 0 {
+		return graphql.Schema{}, errors.New("GraphQL requires at least one collection without Middleware")
+	}
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: g.queries}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: g.mutations}),
+	})
+}
+
+var invalidGraphQLName = regexp.MustCompile(`[^_0-9A-Za-z]+`)
+
+// typeName derives a unique GraphQL type name for t.
+func (g *graphQLSchema) typeName(t reflect.Type) string {
+	if name, ok := g.typeNames[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+
+	if i := strings.Index(name, "["); i >= 0 {
+		args := strings.Split(strings.TrimSuffix(name[i+1:], "]"), ",")
+
+		for j, arg := range args {
+			args[j] = arg[strings.LastIndex(arg, ".")+1:]
+		}
+
+		name = name[:i] + "_" + strings.Join(args, "_")
+	}
+
+	name = invalidGraphQLName.ReplaceAllString(name, "_")
+
+	if name == "" {
+		name = "Object"
+	}
+
+	g.typeNames[t] = g.uniqueName(name)
+
+	return g.typeNames[t]
+}
+
+// uniqueName reserves name, suffixed with a number if it is already taken.
+func (g *graphQLSchema) uniqueName(name string) string {
+	unique := name
+
+	for i := 2; g.names[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+
+	g.names[unique] = true
+
+	return unique
+}
+
+// fieldName converts a slug to a GraphQL field name, e.g. "some-struct" to "someStruct".
+func fieldName(slug string) string {
+	parts := invalidGraphQLName.Split(slug, -1)
+	name := ""
+
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(p[:1]) + p[1:]
+		} else {
+			name += strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+
+	return name
+}
+
+// eachField calls fn with the fields of t as encoded in JSON, flattening embedded structs.
+// Fields whose JSON name is not a valid GraphQL name are skipped.
+func eachField(t reflect.Type, index []int, fn func(f reflect.StructField, name string, index []int)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		idx := append(append([]int{}, index...), i)
+
+		if f.Anonymous && strings.Split(f.Tag.Get("json"), ",")[0] == "" && f.Type.Kind() == reflect.Struct {
+			eachField(f.Type, idx, fn)
+			continue
+		}
+
+		name := jsonName(f)
+
+		if !f.IsExported() || name == "-" || invalidGraphQLName.MatchString(name) {
+			continue
+		}
+
+		fn(f, name, idx)
+	}
+}
+
+func (g *graphQLSchema) output(t reflect.Type) graphql.Output {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case objectIDType:
+		return graphql.ID
+	case timeType, dateTimeType:
+		return graphql.DateTime
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return graphql.Int
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return g.long
+	case reflect.Float32, reflect.Float64:
+		return graphql.Float
+	case reflect.String:
+		return graphql.String
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return g.json
+		}
+
+		return graphql.NewList(g.output(t.Elem()))
+	case reflect.Struct:
+		if o, ok := g.outputs[t]; ok {
+			return o
+		}
+
+		obj := graphql.NewObject(graphql.ObjectConfig{
+			Name: g.typeName(t),
+			Fields: graphql.FieldsThunk(func() graphql.Fields {
+				return g.objectFields(t)
+			}),
+		})
+
+		g.outputs[t] = obj
+
+		return obj
+	default:
+		return g.json
+	}
+}
+
+func (g *graphQLSchema) objectFields(t reflect.Type) graphql.Fields {
+	fields := graphql.Fields{}
+
+	eachField(t, nil, func(f reflect.StructField, name string, index []int) {
+		value := func(p graphql.ResolveParams) (reflect.Value, bool) {
+			v := reflect.ValueOf(p.Source)
+
+			for v.Kind() == reflect.Pointer {
+				if v.IsNil() {
+					return v, false
+				}
+
+				v = v.Elem()
+			}
+
+			fv, err := v.FieldByIndexErr(index)
+
+			return fv, err == nil
+		}
+
+		field := &graphql.Field{
+			Type: g.output(f.Type),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				if fv, ok := value(p); ok {
+					return graphQLValue(fv), nil
+				}
+
+				return nil, nil
+			},
+		}
+
+		if ref, ok := g.documents[f.Tag.Get("ref")]; ok {
+			switch f.Type {
+			case objectIDType:
+				field.Type = ref.typ
+				field.Resolve = func(p graphql.ResolveParams) (any, error) {
+					fv, ok := value(p)
+
+					if !ok || fv.Interface().(primitive.ObjectID).IsZero() {
+						return nil, nil
+					}
+
+					return ref.find(p.Context, fv.Interface().(primitive.ObjectID))
+				}
+			case reflect.SliceOf(objectIDType):
+				field.Type = graphql.NewList(ref.typ)
+				field.Resolve = func(p graphql.ResolveParams) (any, error) {
+					fv, ok := value(p)
+
+					if !ok {
+						return nil, nil
+					}
+
+					var docs []any
+
+					for _, id := range fv.Interface().([]primitive.ObjectID) {
+						doc, err := ref.find(p.Context, id)
+
+						if err != nil {
+							return nil, err
+						}
+
+						docs = append(docs, doc)
+					}
+
+					return docs, nil
+				}
+			}
+		}
+
+		fields[name] = field
+	})
+
+	return fields
+}
+
+// graphQLValue converts v to a value the GraphQL scalars serialize.
+func graphQLValue(v reflect.Value) any {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+
+		v = v.Elem()
+	}
+
+	switch v.Type() {
+	case objectIDType:
+		return v.Interface().(primitive.ObjectID).Hex()
+	case dateTimeType:
+		return v.Interface().(primitive.DateTime).Time()
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+
+		if v.Type().Elem().Kind() == reflect.Struct && v.Type().Elem() != dateTimeType {
+			return v.Interface()
+		}
+
+		values := make([]any, v.Len())
+
+		for i := range values {
+			values[i] = graphQLValue(v.Index(i))
+		}
+
+		return values
+	default:
+		return v.Interface()
+	}
+}
+
+func (g *graphQLSchema) input(t reflect.Type) graphql.Input {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case objectIDType:
+		return graphql.ID
+	case timeType, dateTimeType:
+		return graphql.DateTime
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return graphql.Int
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return g.long
+	case reflect.Float32, reflect.Float64:
+		return graphql.Float
+	case reflect.String:
+		return graphql.String
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return g.json
+		}
+
+		return graphql.NewList(g.input(t.Elem()))
+	case reflect.Struct:
+		if in, ok := g.inputs[t]; ok {
+			return in
+		}
+
+		in := graphql.NewInputObject(graphql.InputObjectConfig{
+			Name: g.typeName(t) + "Input",
+			Fields: graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap {
+				fields := graphql.InputObjectConfigFieldMap{}
+
+				eachField(t, nil, func(f reflect.StructField, name string, _ []int) {
+					fields[name] = &graphql.InputObjectFieldConfig{Type: g.input(f.Type)}
+				})
+
+				return fields
+			}),
+		})
+
+		g.inputs[t] = in
+
+		return in
+	default:
+		return g.json
+	}
+}
+
+func parseLong(v any) any {
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) {
+			return v
+		}
+	case json.Number, int, int64, uint64:
+		return v
+	}
+
+	return nil
+}
+
+// parseLongLiteral keeps the digits of integer literals, which decodeGraphQLInput decodes into
+// the field without the precision loss of a float64.
+func parseLongLiteral(v ast.Value) any {
+	if v, ok := v.(*ast.IntValue); ok {
+		return json.Number(v.Value)
+	}
+
+	return nil
+}
+
+func parseJSONLiteral(v ast.Value) any {
+	switch v := v.(type) {
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.IntValue:
+		i, _ := strconv.ParseInt(v.Value, 10, 64)
+		return i
+	case *ast.FloatValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *ast.ListValue:
+		values := make([]any, len(v.Values))
+
+		for i, item := range v.Values {
+			values[i] = parseJSONLiteral(item)
+		}
+
+		return values
+	case *ast.ObjectValue:
+		values := map[string]any{}
+
+		for _, f := range v.Fields {
+			values[f.Name.Value] = parseJSONLiteral(f.Value)
+		}
+
+		return values
+	default:
+		return nil
+	}
+}
+
+// injectGraphQL serves the schema generated from the collections on rg.
+func (s *Scaffold) injectGraphQL(rg *gin.RouterGroup) error {
+	schema, err := newGraphQLSchema(s.opts.Collections)
+
+	if err != nil {
+		return err
+	}
+
+	maxDepth := s.opts.GraphQL.MaxDepth
+
+	rg.POST("", func(c *gin.Context) {
+		if c.ContentType() != "application/json" {
+			http.BadRequest(c, nil)
+			return
+		}
+
+		var req struct {
+			Query         string         `json:"query"`
+			OperationName string         `json:"operationName"`
+			Variables     map[string]any `json:"variables"`
+		}
+
+		if err := c.BindJSON(&req); err != nil {
+			http.BadRequest(c, err)
+			return
+		}
+
+		// Syntax errors are left for graphql.Do to report
+		if doc, err := parser.Parse(parser.ParseParams{Source: req.Query}); err == nil && graphQLDepth(doc) > maxDepth {
+			c.JSON(200, &graphql.Result{Errors: gqlerrors.FormatErrors(
+				fmt.Errorf("query exceeds the maximum depth of %d", maxDepth),
+			)})
+			return
+		}
+
+		c.JSON(200, graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        c,
+		}))
+	})
+
+	return nil
+}
+
+// graphQLDepth returns how deeply the operations in doc nest fields.
+func graphQLDepth(doc *ast.Document) int {
+	fragments := map[string]*ast.FragmentDefinition{}
+
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[f.Name.Value] = f
+		}
+	}
+
+	// Fragment depths are memoized so spreading fragments repeatedly stays linear, and fragments
+	// being visited are skipped so cycles, which validation rejects, terminate
+	memo := map[string]int{}
+	visiting := map[string]bool{}
+
+	var depth func(set *ast.SelectionSet) int
+
+	depth = func(set *ast.SelectionSet) int {
+		deepest := 0
+
+		if set == nil {
+			return deepest
+		}
+
+		for _, sel := range set.Selections {
+			d := 0
+
+			switch sel := sel.(type) {
+			case *ast.Field:
+				if strings.HasPrefix(sel.Name.Value, "__") {
+					continue
+				}
+
+				d = 1 + depth(sel.SelectionSet)
+			case *ast.InlineFragment:
+				d = depth(sel.SelectionSet)
+			case *ast.FragmentSpread:
+				name := sel.Name.Value
+				f, ok := fragments[name]
+
+				if !ok || visiting[name] {
+					continue
+				}
+
+				if _, ok := memo[name]; !ok {
+					visiting[name] = true
+					memo[name] = depth(f.SelectionSet)
+					visiting[name] = false
+				}
+
+				d = memo[name]
+			}
+
+			deepest = max(deepest, d)
+		}
+
+		return deepest
+	}
+
+	deepest := 0
+
+	for _, def := range doc.Definitions {
+		if op, ok := def.(*ast.OperationDefinition); ok {
+			deepest = max(deepest, depth(op.SelectionSet))
+		}
+	}
+
+	return deepest
+}
+
+// graphql adds the queries and mutations of the collection to g. Resolvers use the same methods,
+// hooks and permissions as the REST handlers. Collections with Middleware are left out, as
+// resolvers cannot run it.
+func (c *C[T]) graphql(g *graphQLSchema) {
+	if len(c.middleware) > 0 {
+		return
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	data := g.output(t)
+	base := fieldName(c.slug)
+
+	name := base
+
+	if t.Kind() == reflect.Struct {
+		name = g.typeName(t)
+	}
+
+	doc := graphql.NewObject(graphql.ObjectConfig{
+		Name: g.uniqueName(name + "Document"),
+		Fields: graphql.Fields{
+			"id": {
+				Type: graphql.NewNonNull(graphql.ID),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*Document[T]).ID.Hex(), nil
+				},
+			},
+			"created": {
+				Type: graphql.NewNonNull(graphql.DateTime),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*Document[T]).Created.Time(), nil
+				},
+			},
+			"last_updated": {
+				Type: graphql.NewNonNull(graphql.DateTime),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*Document[T]).LastUpdated.Time(), nil
+				},
+			},
+			"document": {
+				Type: data,
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(*Document[T]).Data, nil
+				},
+			},
+		},
+	})
+
+	g.documents[c.slug] = &graphQLRef{
+		typ: doc,
+		find: func(ctx context.Context, id primitive.ObjectID) (any, error) {
+			if err := c.allowed(ctx, auth.OpRead); err != nil {
+				return nil, err
+			}
+
+			return c.graphQLFind(ctx, id)
+		},
+	}
+
+	idArg := graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}}
+	input := g.input(t)
+	title := strings.ToUpper(base[:1]) + base[1:]
+
+	g.queries[base] = &graphql.Field{
+		Type: doc,
+		Args: idArg,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			if err := c.graphQLAuthorize(p.Context, auth.OpRead); err != nil {
+				return nil, err
+			}
+
+			id, err := graphQLID(p.Args["id"])
+
+			if err != nil {
+				return nil, err
+			}
+
+			return c.graphQLFind(p.Context, id)
+		},
+	}
+
+	g.queries[base+"List"] = &graphql.Field{
+		Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(doc))),
+		Args: graphql.FieldConfigArgument{
+			"filter": {Type: graphql.NewList(graphql.NewNonNull(g.filter))},
+			"sort": {
+				Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+				Description: "Fields to sort by, descending when prefixed with \"-\"",
+			},
+			"limit": {Type: graphql.Int, DefaultValue: 10},
+			"page":  {Type: graphql.Int, DefaultValue: 1},
+		},
+		Resolve: c.graphQLList,
+	}
+
+	g.mutations["create"+title] = &graphql.Field{
+		Type: doc,
+		Args: graphql.FieldConfigArgument{"input": {Type: graphql.NewNonNull(input)}},
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			if err := c.graphQLAuthorize(p.Context, auth.OpCreate); err != nil {
+				return nil, err
+			}
+
+			var data T
+
+			if err := decodeGraphQLInput(p.Args["input"], &data); err != nil {
+				return nil, err
+			}
+
+			return c.Insert(p.Context, data)
+		},
+	}
+
+	g.mutations["update"+title] = &graphql.Field{
+		Type: doc,
+		Args: graphql.FieldConfigArgument{
+			"id":    {Type: graphql.NewNonNull(graphql.ID)},
+			"input": {Type: graphql.NewNonNull(input)},
+		},
+		Resolve: c.graphQLUpdate,
+	}
+
+	if !c.deletable {
+		return
+	}
+
+	g.mutations["delete"+title] = &graphql.Field{
+		Type: doc,
+		Args: idArg,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			if err := c.graphQLAuthorize(p.Context, auth.OpDelete); err != nil {
+				return nil, err
+			}
+
+			id, err := graphQLID(p.Args["id"])
+
+			if err != nil {
+				return nil, err
+			}
+
+			doc, err := c.FindById(p.Context, id)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if err := doc.Delete(p.Context); err != nil {
+				return nil, err
+			}
+
+			return doc, nil
+		},
+	}
+}
+
+// graphQLAuthorize checks permissions and rate limits for op like the REST handlers.
+func (c *C[T]) graphQLAuthorize(ctx context.Context, op auth.Operation) error {
+	if err := c.allowed(ctx, op); err != nil {
+		return err
+	}
+
+	gc, ok := ctx.(*gin.Context)
+
+	if c.limiter == nil || !ok {
+		return nil
+	}
+
+	scopes := map[string]auth.Operation{c.slug: auth.OpAll}
+
+	if op != auth.OpAll {
+		scopes[c.slug+":"+string(op)] = op
+	}
+
+	for scope, op := range scopes {
+		l, ok := c.rateLimits[op]
+
+		if !ok {
+			continue
+		}
+
+		res, err := c.limiter.Take(gc, scope, l)
+
+		if err != nil {
+			return err
+		}
+
+		if !res.Allowed {
+			return http.ErrTooManyRequests{Message: "too many requests"}
+		}
+	}
+
+	return nil
+}
+
+// graphQLFind returns the document with id, or nil when it does not exist.
+func (c *C[T]) graphQLFind(ctx context.Context, id primitive.ObjectID) (any, error) {
+	doc, err := c.FindById(ctx, id)
+
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func (c *C[T]) graphQLList(p graphql.ResolveParams) (any, error) {
+	if err := c.graphQLAuthorize(p.Context, auth.OpList); err != nil {
+		return nil, err
+	}
+
+	var queries []query.Query
+
+	filters, _ := p.Args["filter"].([]any)
+
+	for _, f := range filters {
+		f := f.(map[string]any)
+
+		q, err := c.fields.Condition(f["field"].(string), f["op"].(string), f["value"].(string))
+
+		if err != nil {
+			return nil, err
+		}
+
+		queries = append(queries, q)
+	}
+
+	var q query.Query = &query.Logical{Operator: query.And, Queries: queries}
+
+	if len(queries) == 0 {
+		q = query.Empty()
+	}
+
+	if fields, ok := p.Args["sort"].([]any); ok {
+		var names []string
+
+		for _, f := range fields {
+			names = append(names, f.(string))
+		}
+
+		var err error
+		q, err = c.fields.Sort(q, names)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	limit, page := p.Args["limit"].(int), p.Args["page"].(int)
+
+	if limit < 1 || page < 1 {
+		return nil, http.ErrBadRequest{Message: "limit and page must be greater than 0"}
+	}
+
+	docs, err := c.FindMany(p.Context, q, limit, page)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*Document[T], len(docs))
+
+	for i := range docs {
+		results[i] = &docs[i]
+	}
+
+	return results, nil
+}
+
+func (c *C[T]) graphQLUpdate(p graphql.ResolveParams) (any, error) {
+	if err := c.graphQLAuthorize(p.Context, auth.OpUpdate); err != nil {
+		return nil, err
+	}
+
+	id, err := graphQLID(p.Args["id"])
+
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := c.FindById(p.Context, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the input into a T so each value has the type of its field, as SetMany requires
+	var data T
+
+	if err := decodeGraphQLInput(p.Args["input"], &data); err != nil {
+		return nil, err
+	}
+
+	input, _ := p.Args["input"].(map[string]any)
+	updates := bson.M{}
+	v := reflect.ValueOf(data)
+
+	if v.Kind() == reflect.Struct {
+		// Fields of embedded structs are set on a copy of the current struct, so the fields the
+		// input leaves out are kept when it is replaced
+		merged := reflect.New(v.Type()).Elem()
+		merged.Set(reflect.ValueOf(doc.Data).Elem())
+
+		eachField(v.Type(), nil, func(f reflect.StructField, name string, index []int) {
+			top := v.Type().Field(index[0])
+			field := merged.FieldByIndex(index)
+
+			if _, ok := input[name]; !ok || !top.IsExported() || !field.CanSet() {
+				return
+			}
+
+			field.Set(v.FieldByIndex(index))
+			updates[top.Name] = merged.Field(index[0]).Interface()
+		})
+	}
+
+	updated, err := c.update(p.Context, doc.ID, doc.Data, &updates)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := doc.SetMany(p.Context, *updated); err != nil {
+		return nil, err
+	}
+
+	return doc, nil
+}
+
+func graphQLID(v any) (primitive.ObjectID, error) {
+	s, _ := v.(string)
+	id, err := primitive.ObjectIDFromHex(s)
+
+	if err != nil {
+		return id, http.ErrBadRequest{Message: "invalid id"}
+	}
+
+	return id, nil
+}
+
+// decodeGraphQLInput decodes an input object into v through its JSON encoding, whose names the
+// input fields share.
+func decodeGraphQLInput(input any, v any) error {
+	b, err := json.Marshal(input)
+
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return http.ErrBadRequest{Message: err.Error()}
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package scaffold
+
+import (
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Ownership struct {
+	Owner string `bson:"owner" json:"owner"`
+	Team  string `bson:"team" json:"team"`
+}
+
+type Audit struct {
+	Editor string `bson:"editor" json:"editor"`
+}
+
+type Labels struct {
+	Label string `bson:"label" json:"label"`
+}
+
+type counter struct {
+	Ownership
+	Audit
+	Labels `bson:",inline"`
+	Name   string `bson:"name" json:"name"`
+	Count  uint64 `bson:"count" json:"count"`
+	Meta   Audit  `bson:"meta" json:"meta"`
+}
+
+func TestGraphQL(t *testing.T) {
+	counters := NewCollection(CollectionOpts[counter]{Name: "Counters", Slug: "counters"})
+	guarded := NewCollection(CollectionOpts[note]{
+		Name:       "Guarded",
+		Slug:       "guarded",
+		Middleware: []gin.HandlerFunc{func(c *gin.Context) { c.AbortWithStatus(nethttp.StatusForbidden) }},
+	})
+
+	_, h := testScaffold(t, ScaffoldOpts{
+		Collections: []Collection{counters, guarded},
+		GraphQL:     &GraphQLOpts{MaxDepth: 3},
+	})
+
+	do := func(t *testing.T, query string, variables map[string]any) (map[string]any, string) {
+		t.Helper()
+
+		w := request(h, "POST", "/graphql", map[string]any{"query": query, "variables": variables})
+		expectStatus(t, w, nethttp.StatusOK)
+
+		var res struct {
+			Data   map[string]any `json:"data"`
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		}
+
+		// Numbers are kept exact to compare Long values
+		dec := json.NewDecoder(w.Body)
+		dec.UseNumber()
+
+		if err := dec.Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		var errs []string
+
+		for _, e := range res.Errors {
+			errs = append(errs, e.Message)
+		}
+
+		return res.Data, strings.Join(errs, "; ")
+	}
+
+	t.Run("collections with middleware are left out", func(t *testing.T) {
+		if _, errs := do(t, `{ guardedList { id } }`, nil); !strings.Contains(errs, "guardedList") {
+			t.Fatalf("guarded collection was queried: %q", errs)
+		}
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			query string
+			ok    bool
+		}{
+			{"within limit", `{ countersList { document { name } } }`, true},
+			{"too deep", `{ countersList { document { meta { editor } } } }`, false},
+			{"too deep through fragments", `{ ...a } fragment a on Query { countersList { ...b } } fragment b on counterDocument { document { meta { editor } } }`, false},
+			{"introspection is not counted", `{ countersList { document { __typename } } __schema { types { fields { type { ofType { ofType { name } } } } } } }`, true},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, errs := do(t, tt.query, nil)
+
+				if tt.ok && errs != "" {
+					t.Fatal(errs)
+				}
+
+				if !tt.ok && !strings.Contains(errs, "maximum depth") {
+					t.Fatalf("query was not rejected: %q", errs)
+				}
+			})
+		}
+	})
+
+	t.Run("long", func(t *testing.T) {
+		data, errs := do(t, `mutation { createCounters(input: {name: "max", count: 9007199254740993}) { id document { count } } }`, nil)
+
+		if errs != "" {
+			t.Fatal(errs)
+		}
+
+		created := data["createCounters"].(map[string]any)
+
+		if count := created["document"].(map[string]any)["count"]; count != json.Number("9007199254740993") {
+			t.Fatalf("count = %v", count)
+		}
+
+		id, err := primitive.ObjectIDFromHex(created["id"].(string))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		doc, err := counters.FindById(context.Background(), id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if doc.Data.Count != 9007199254740993 {
+			t.Fatalf("stored count = %d", doc.Data.Count)
+		}
+	})
+
+	t.Run("embedded fields are updated", func(t *testing.T) {
+		doc, err := counters.Insert(context.Background(), counter{
+			Ownership: Ownership{Owner: "ada", Team: "core"},
+			Audit:     Audit{Editor: "ada"},
+			Labels:    Labels{Label: "new"},
+			Name:      "embedded",
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, errs := do(t, `mutation($id: ID!) { updateCounters(id: $id, input: {owner: "grace", editor: "grace", label: "hot"}) { id } }`, map[string]any{"id": doc.ID.Hex()})
+
+		if errs != "" {
+			t.Fatal(errs)
+		}
+
+		var stored bson.M
+
+		if err := counters.mc.FindOne(context.Background(), bson.M{"_id": doc.ID}).Decode(&stored); err != nil {
+			t.Fatal(err)
+		}
+
+		ownership, _ := stored["ownership"].(bson.M)
+		audit, _ := stored["audit"].(bson.M)
+
+		if ownership["owner"] != "grace" || ownership["team"] != "core" || audit["editor"] != "grace" || stored["name"] != "embedded" {
+			t.Fatalf("embedded structs stored as %v", stored)
+		}
+
+		// Inline structs are stored flattened
+		if _, ok := stored["labels"]; ok || stored["label"] != "hot" {
+			t.Fatalf("inline struct stored as %v", stored)
+		}
+
+		found, err := counters.FindById(context.Background(), doc.ID)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if found.Data.Label != "hot" || found.Data.Owner != "grace" {
+			t.Fatalf("read back %+v", found.Data)
+		}
+	})
+}
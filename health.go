@@ -0,0 +1,128 @@
+package scaffold
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/gin-gonic/gin"
+)
+
+const healthCheckTimeout = 5 * time.Second
+
+// HealthCheck reports an error when a dependency of the application is unhealthy.
+type HealthCheck func(context.Context) error
+
+type healthResult struct {
+	Status string `json:"status"`
+}
+
+type healthReport struct {
+	Status string                  `json:"status"`
+	Checks map[string]healthResult `json:"checks,omitempty"`
+}
+
+type health struct {
+	mu     sync.RWMutex
+	checks map[string]HealthCheck
+}
+
+func newHealth(checks map[string]HealthCheck) *health {
+	h := &health{checks: map[string]HealthCheck{}}
+
+	for name, check := range checks {
+		h.checks[name] = check
+	}
+
+	return h
+}
+
+func (h *health) add(name string, check HealthCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks[name] = check
+}
+
+// run runs every check. Reports only carry the state of each check as they are served to
+// anonymous callers, errors are logged instead.
+func (h *health) run(ctx context.Context) healthReport {
+	h.mu.RLock()
+	checks := make(map[string]HealthCheck, len(h.checks))
+
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	h.mu.RUnlock()
+
+	log := http.Logger(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	report := healthReport{Status: "ok", Checks: map[string]healthResult{}}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for name, check := range checks {
+		wg.Add(1)
+
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := check(ctx)
+			res := healthResult{Status: "ok"}
+
+			if err != nil {
+				res.Status = "error"
+				log.Warn("Health check failed",
+					slog.String("check", name),
+					slog.Duration("duration", time.Since(start)),
+					slog.Any("error", err),
+				)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			report.Checks[name] = res
+
+			if err != nil {
+				report.Status = "unavailable"
+			}
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+func (h *health) inject(r gin.IRouter) {
+	r.GET("/healthz", h.handleLive)
+	r.GET("/readyz", h.handleReady)
+}
+
+func (h *health) handleLive(ctx *gin.Context) {
+	http.Ok(ctx, healthReport{Status: "ok"})
+}
+
+func (h *health) handleReady(ctx *gin.Context) {
+	report := h.run(ctx)
+
+	if report.Status != "ok" {
+		http.Unavailable(ctx, report)
+		return
+	}
+
+	http.Ok(ctx, report)
+}
+
+// AddHealthCheck registers a check which must pass for the instance to report ready on /readyz.
+func (s *Scaffold) AddHealthCheck(name string, check HealthCheck) {
+	s.health.add(name, check)
+}
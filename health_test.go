@@ -0,0 +1,29 @@
+package scaffold
+
+import (
+	"context"
+	"errors"
+	nethttp "net/http"
+	"strings"
+	"testing"
+)
+
+func TestReady(t *testing.T) {
+	var failing error
+
+	s, h := testScaffold(t, ScaffoldOpts{})
+	s.AddHealthCheck("cache", func(context.Context) error { return failing })
+
+	expectStatus(t, request(h, "GET", "/healthz", nil), nethttp.StatusOK)
+	expectStatus(t, request(h, "GET", "/readyz", nil), nethttp.StatusOK)
+
+	failing = errors.New("dial tcp 10.0.0.12:6379: connection refused")
+
+	w := request(h, "GET", "/readyz", nil)
+	expectStatus(t, w, nethttp.StatusServiceUnavailable)
+
+	// Anonymous callers only learn which check failed
+	if body := w.Body.String(); strings.Contains(body, "10.0.0.12") || !strings.Contains(body, `"cache":{"status":"error"}`) {
+		t.Fatalf("unexpected readiness report %s", body)
+	}
+}
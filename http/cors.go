@@ -0,0 +1,117 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CORSOpts struct {
+	// AllowOrigins lists the origins allowed to make requests. "*" allows any origin and a
+	// leading wildcard label such as "https://*.example.com" allows any subdomain.
+	AllowOrigins []string
+	// AllowMethods defaults to GET, POST, PATCH, DELETE and OPTIONS.
+	AllowMethods []string
+	// AllowHeaders defaults to Content-Type, Authorization and X-API-Key.
+	AllowHeaders  []string
+	ExposeHeaders []string
+	// AllowCredentials lets browsers send cookies and credentials. It cannot be combined with
+	// allowing any origin, list the trusted origins instead.
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache preflight responses.
+	MaxAge time.Duration
+}
+
+// Validate rejects allowing credentials from any origin, which would let every site make
+// authenticated requests on behalf of visitors.
+func (o CORSOpts) Validate() error {
+	if o.AllowCredentials && slices.Contains(o.AllowOrigins, "*") {
+		return errors.New("cors: credentials cannot be allowed from any origin")
+	}
+
+	return nil
+}
+
+// allowed returns the Access-Control-Allow-Origin value for origin, "*" when it is only allowed
+// as any origin.
+func (o CORSOpts) allowed(origin string) (string, bool) {
+	anyOrigin := false
+
+	for _, allowed := range o.AllowOrigins {
+		if allowed == "*" {
+			anyOrigin = true
+			continue
+		}
+
+		if strings.EqualFold(allowed, origin) {
+			return origin, true
+		}
+
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+
+		if ok && strings.HasPrefix(origin, scheme+"://") && strings.HasSuffix(origin, "."+host) {
+			return origin, true
+		}
+	}
+
+	return "*", anyOrigin
+}
+
+func corsMiddleware(opts CORSOpts) gin.HandlerFunc {
+	if opts.AllowMethods == nil {
+		opts.AllowMethods = []string{http.MethodGet, http.MethodPost, http.MethodPatch, http.MethodDelete, http.MethodOptions}
+	}
+
+	if opts.AllowHeaders == nil {
+		opts.AllowHeaders = []string{"Content-Type", "Authorization", "X-API-Key"}
+	}
+
+	methods := strings.Join(opts.AllowMethods, ", ")
+	headers := strings.Join(opts.AllowHeaders, ", ")
+	expose := strings.Join(opts.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(opts.MaxAge.Seconds()))
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+
+		c.Writer.Header().Add("Vary", "Origin")
+
+		allowed, ok := opts.allowed(origin)
+
+		if origin == "" || !ok {
+			c.Next()
+			return
+		}
+
+		c.Header("Access-Control-Allow-Origin", allowed)
+
+		// Browsers refuse credentials for "*", origins allowed that way never receive them
+		if opts.AllowCredentials && allowed != "*" {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		// Preflight request
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+
+			if opts.MaxAge > 0 {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
+
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		if expose != "" {
+			c.Header("Access-Control-Expose-Headers", expose)
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name        string
+		opts        CORSOpts
+		origin      string
+		allow       string
+		credentials string
+	}{
+		{"listed", CORSOpts{AllowOrigins: []string{"https://app.example.com"}, AllowCredentials: true}, "https://app.example.com", "https://app.example.com", "true"},
+		{"subdomain", CORSOpts{AllowOrigins: []string{"https://*.example.dev"}, AllowCredentials: true}, "https://a.example.dev", "https://a.example.dev", "true"},
+		{"not listed", CORSOpts{AllowOrigins: []string{"https://app.example.com"}}, "https://evil.example", "", ""},
+		{"any origin", CORSOpts{AllowOrigins: []string{"*"}}, "https://evil.example", "*", ""},
+		// Middleware used without validation still never reflects origins for "*"
+		{"any origin with credentials", CORSOpts{AllowOrigins: []string{"*"}, AllowCredentials: true}, "https://evil.example", "*", ""},
+		{"listed beside any origin", CORSOpts{AllowOrigins: []string{"*", "https://app.example.com"}}, "https://app.example.com", "https://app.example.com", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(corsMiddleware(tt.opts))
+			r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Origin", tt.origin)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.allow {
+				t.Fatalf("Access-Control-Allow-Origin = %q", got)
+			}
+
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.credentials {
+				t.Fatalf("Access-Control-Allow-Credentials = %q", got)
+			}
+		})
+	}
+}
+
+func TestCORSValidate(t *testing.T) {
+	if err := (CORSOpts{AllowOrigins: []string{"*"}, AllowCredentials: true}).Validate(); err == nil {
+		t.Fatal("credentials from any origin were accepted")
+	}
+
+	if err := (CORSOpts{AllowOrigins: []string{"https://app.example.com"}, AllowCredentials: true}).Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -6,6 +6,31 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// writeError records err for the access log and writes it as the response body.
+func writeError(c *gin.Context, status int, err error) {
+	RecordError(c, err)
+	c.JSON(status, Response{Error: err.Error(), RequestID: RequestID(c)})
+}
+
+// RecordError records err for the access log without writing a response, for errors which
+// occur once a streamed response has started.
+func RecordError(c *gin.Context, err error) {
+	c.Set(errorKey, err.Error())
+}
+
+// ResponseError returns the error message written in response to the request, if any.
+func ResponseError(c *gin.Context) (string, bool) {
+	err, ok := c.Get(errorKey)
+
+	if !ok {
+		return "", false
+	}
+
+	msg, ok := err.(string)
+
+	return msg, ok
+}
+
 type ErrInternal struct {
 	Message string
 }
@@ -19,7 +44,7 @@ func InternalError(c *gin.Context, err error) {
 		err = ErrInternal{Message: "internal server error"}
 	}
 
-	c.JSON(http.StatusInternalServerError, Response{Error: err.Error()})
+	writeError(c, http.StatusInternalServerError, err)
 }
 
 type ErrUnauthorized struct {
@@ -35,7 +60,7 @@ func Unauthorized(c *gin.Context, err error) {
 		err = ErrUnauthorized{Message: "unauthorized"}
 	}
 
-	c.JSON(http.StatusUnauthorized, Response{Error: err.Error()})
+	writeError(c, http.StatusUnauthorized, err)
 }
 
 type ErrNotFound struct {
@@ -51,7 +76,7 @@ func NotFound(c *gin.Context, err error) {
 		err = ErrNotFound{Message: "not found"}
 	}
 
-	c.JSON(http.StatusNotFound, Response{Error: err.Error()})
+	writeError(c, http.StatusNotFound, err)
 }
 
 type ErrMethodNotAllowed struct {
@@ -67,7 +92,7 @@ func MethodNotAllowed(c *gin.Context, err error) {
 		err = ErrMethodNotAllowed{Message: "method not allowed"}
 	}
 
-	c.JSON(http.StatusMethodNotAllowed, Response{Error: err.Error()})
+	writeError(c, http.StatusMethodNotAllowed, err)
 }
 
 type ErrBadRequest struct {
@@ -83,7 +108,7 @@ func BadRequest(c *gin.Context, err error) {
 		err = ErrBadRequest{Message: "bad request"}
 	}
 
-	c.JSON(http.StatusBadRequest, Response{Error: err.Error()})
+	writeError(c, http.StatusBadRequest, err)
 }
 
 type ErrForbidden struct {
@@ -98,7 +123,22 @@ func Forbidden(c *gin.Context, err error) {
 	if err == nil || err.Error() == "" {
 		err = ErrForbidden{Message: "forbidden"}
 	}
-	c.JSON(http.StatusForbidden, Response{Error: err.Error()})
+	writeError(c, http.StatusForbidden, err)
+}
+
+type ErrTooManyRequests struct {
+	Message string
+}
+
+func (e ErrTooManyRequests) Error() string {
+	return e.Message
+}
+
+func TooManyRequests(c *gin.Context, err error) {
+	if err == nil || err.Error() == "" {
+		err = ErrTooManyRequests{Message: "too many requests"}
+	}
+	writeError(c, http.StatusTooManyRequests, err)
 }
 
 func Error(c *gin.Context, err error) {
@@ -115,6 +155,8 @@ func Error(c *gin.Context, err error) {
 		BadRequest(c, err)
 	case ErrForbidden:
 		Forbidden(c, err)
+	case ErrTooManyRequests:
+		TooManyRequests(c, err)
 	default:
 		InternalError(c, err)
 	}
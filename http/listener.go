@@ -0,0 +1,195 @@
+package http
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// certCheckInterval limits how often certificate files are checked for changes.
+const certCheckInterval = time.Second
+
+// Listener is an address the server accepts connections on.
+type Listener struct {
+	// Network is "tcp" (the default) or "unix".
+	Network string
+	// Address is a host:port for TCP listeners or a socket path for Unix listeners.
+	Address string
+	// TLS serves HTTPS, with HTTP/2 negotiated through ALPN.
+	TLS *TLSOpts
+	// H2C serves HTTP/2 over cleartext connections, intended for traffic from trusted proxies.
+	H2C bool
+}
+
+type TLSOpts struct {
+	// CertFile and KeyFile are PEM encoded. They are reloaded when changed on disk, so renewed
+	// certificates are picked up without a restart.
+	CertFile string
+	KeyFile  string
+	// MinVersion defaults to TLS 1.2.
+	MinVersion uint16
+}
+
+func (l Listener) network() string {
+	if l.Network == "" {
+		return "tcp"
+	}
+
+	return l.Network
+}
+
+func (l Listener) listen() (net.Listener, error) {
+	if l.network() == "unix" {
+		// Remove a socket left behind by a previous process, but not one another process is
+		// still serving on
+		if fi, err := os.Stat(l.Address); err == nil && fi.Mode().Type() == fs.ModeSocket {
+			conn, err := net.DialTimeout("unix", l.Address, time.Second)
+
+			if err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("listen unix %s: socket is in use", l.Address)
+			}
+
+			if !errors.Is(err, syscall.ECONNREFUSED) {
+				return nil, err
+			}
+
+			if err := os.Remove(l.Address); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return net.Listen(l.network(), l.Address)
+}
+
+// server creates the http.Server for the listener, serving handler.
+func (l Listener) server(handler http.Handler, log *slog.Logger) (*http.Server, error) {
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	if l.TLS != nil {
+		reloader, err := newCertReloader(l.TLS.CertFile, l.TLS.KeyFile, log)
+
+		if err != nil {
+			return nil, err
+		}
+
+		minVersion := l.TLS.MinVersion
+
+		if minVersion == 0 {
+			minVersion = tls.VersionTLS12
+		}
+
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     minVersion,
+			GetCertificate: reloader.getCertificate,
+		}
+	} else if l.H2C {
+		srv.Handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	return srv, nil
+}
+
+// certReloader serves a certificate from disk, reloading it when the files change.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	log      *slog.Logger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(certFile string, keyFile string, log *slog.Logger) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("tls requires a certificate and key file")
+	}
+
+	r := &certReloader{certFile: certFile, keyFile: keyFile, log: log}
+
+	modTime, err := r.latestModTime()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+
+	for _, f := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(f)
+
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+
+	return latest, nil
+}
+
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+
+	if err != nil {
+		return fmt.Errorf("loading certificate: %w", err)
+	}
+
+	r.cert = &cert
+	r.modTime = modTime
+
+	return nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.lastCheck) < certCheckInterval {
+		return r.cert, nil
+	}
+
+	r.lastCheck = time.Now()
+
+	modTime, err := r.latestModTime()
+
+	if err != nil || !modTime.After(r.modTime) {
+		return r.cert, nil
+	}
+
+	// Keep serving the previous certificate if the new files are incomplete or invalid
+	if err := r.load(modTime); err != nil {
+		r.log.Warn("Failed to reload TLS certificate", slog.Any("error", err))
+	} else {
+		r.log.Info("Reloaded TLS certificate", slog.String("file", r.certFile))
+	}
+
+	return r.cert, nil
+}
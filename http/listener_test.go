@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	addr := filepath.Join(t.TempDir(), "scaffold.sock")
+	l := Listener{Network: "unix", Address: addr}
+
+	// A socket left behind by a process which exited is replaced
+	stale, err := net.Listen("unix", addr)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	ln, err := l.listen()
+
+	if err != nil {
+		t.Fatalf("stale socket was not replaced: %v", err)
+	}
+
+	// A socket which is being served on is left alone
+	if _, err := l.listen(); err == nil {
+		t.Fatal("listened on a socket in use")
+	}
+
+	if conn, err := net.Dial("unix", addr); err != nil {
+		t.Fatalf("socket in use was removed: %v", err)
+	} else {
+		conn.Close()
+	}
+
+	ln.Close()
+}
@@ -1,12 +1,107 @@
 package http
 
 import (
+	"context"
+	"log/slog"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
-func (h *HttpServer) loggingMiddleware() gin.HandlerFunc {
+const (
+	// LoggerKey is the gin key under which the request-scoped logger is stored.
+	LoggerKey = "logger"
+
+	logAttrsKey = "scaffold.log_attrs"
+	errorKey    = "scaffold.error"
+)
+
+type loggerKey struct{}
+
+// Logger returns the request-scoped logger attached to ctx, or slog.Default when there is none.
+func Logger(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return l
+		}
+
+		if l, ok := ctx.Value(LoggerKey).(*slog.Logger); ok {
+			return l
+		}
+	}
+
+	return slog.Default()
+}
+
+// WithLogger returns a copy of ctx carrying the given logger.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+func setLogger(c *gin.Context, l *slog.Logger) {
+	c.Set(LoggerKey, l)
+	c.Request = c.Request.WithContext(WithLogger(c.Request.Context(), l))
+}
+
+// AddLogAttrs adds attributes to the request-scoped logger and the access log entry of the
+// request.
+func AddLogAttrs(c *gin.Context, attrs ...slog.Attr) {
+	existing, _ := c.Get(logAttrsKey)
+	all, _ := existing.([]slog.Attr)
+	c.Set(logAttrsKey, append(all[:len(all):len(all)], attrs...))
+
+	args := make([]any, len(attrs))
+
+	for i, a := range attrs {
+		args[i] = a
+	}
+
+	setLogger(c, Logger(c).With(args...))
+}
+
+func loggingMiddleware(log *slog.Logger) gin.HandlerFunc {
+	if log == nil {
+		log = slog.Default()
+	}
+
 	return func(c *gin.Context) {
-		h.log.Printf("HTTP %s %s\n", c.Request.Method, c.Request.URL.Path)
+		start := time.Now()
+
+		reqLog := log.With(
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("request_id", RequestID(c)),
+		)
+
+		setLogger(c, reqLog)
+
 		c.Next()
+
+		// Attributes added during the request, read from the gin context since handlers may have
+		// replaced the request context carrying the logger
+		added, _ := c.Get(logAttrsKey)
+		attrs, _ := added.([]slog.Attr)
+
+		status := c.Writer.Status()
+		attrs = append(attrs,
+			slog.Int("status", status),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client", c.ClientIP()),
+		)
+
+		if err, ok := c.Get(errorKey); ok {
+			attrs = append(attrs, slog.Any("error", err))
+		}
+
+		level := slog.LevelInfo
+
+		if status >= 500 {
+			level = slog.LevelError
+		} else if status >= 400 {
+			level = slog.LevelWarn
+		}
+
+		reqLog.LogAttrs(c, level, "HTTP request", attrs...)
 	}
 }
@@ -0,0 +1,59 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAccessLog(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultErrorWriter = io.Discard
+
+	var buf bytes.Buffer
+
+	r := gin.New()
+	r.Use(Middleware(Opts{Logger: slog.New(slog.NewJSONHandler(&buf, nil))})...)
+	r.GET("/attrs", func(c *gin.Context) {
+		AddLogAttrs(c, slog.String("tenant", "acme"))
+		// Handlers replacing the request context must not lose the attributes
+		c.Request = c.Request.WithContext(context.Background())
+		c.Status(http.StatusOK)
+	})
+	r.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+
+	tests := []struct {
+		path   string
+		status float64
+		tenant any
+	}{
+		{"/attrs", http.StatusOK, "acme"},
+		{"/panic", http.StatusInternalServerError, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			buf.Reset()
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+
+			var entry map[string]any
+
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("no access log entry: %q", buf.String())
+			}
+
+			if entry["msg"] != "HTTP request" || entry["status"] != tt.status || entry["tenant"] != tt.tenant || entry["request_id"] == nil {
+				t.Fatalf("unexpected access log entry %v", entry)
+			}
+		})
+	}
+}
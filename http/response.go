@@ -8,8 +8,9 @@ import (
 )
 
 type Response struct {
-	Error string      `json:"error,omitempty"`
-	Data  interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	RequestID string      `json:"request_id,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
 }
 
 type PaginatedResponse struct {
@@ -36,3 +37,7 @@ func Paginated[T any](c *gin.Context, page int, data []T) {
 func Created[T any](c *gin.Context, data T) {
 	c.JSON(http.StatusCreated, Response{Data: data})
 }
+
+func Unavailable[T any](c *gin.Context, data T) {
+	c.JSON(http.StatusServiceUnavailable, Response{Error: "service unavailable", RequestID: RequestID(c), Data: data})
+}
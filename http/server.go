@@ -1,66 +1,184 @@
 package http
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 )
 
 type HttpServer struct {
-	log    *log.Logger
-	router *gin.Engine
-	server *http.Server
-	close  chan struct{}
+	log       *slog.Logger
+	router    *gin.Engine
+	listeners []Listener
+	servers   []*http.Server
+	errs      chan error
 }
 
-func Create(log *log.Logger, address string) *HttpServer {
+type Opts struct {
+	Logger *slog.Logger
+	// Address is a TCP address to listen on, used when Listeners is empty.
+	Address         string
+	Listeners       []Listener
+	CORS            *CORSOpts
+	SecurityHeaders *SecurityHeadersOpts
+	// TrustedProxies are the addresses or CIDR ranges of proxies whose X-Forwarded-For and
+	// X-Real-IP headers are believed when determining the client IP. When empty, no proxy is
+	// trusted and the client IP is the connection's remote address.
+	TrustedProxies []string
+}
+
+func Create(opts Opts) (*HttpServer, error) {
 	gin.SetMode(gin.ReleaseMode)
 
+	if len(opts.Listeners) == 0 {
+		opts.Listeners = []Listener{{Address: opts.Address}}
+	}
+
 	r := &HttpServer{
-		log:    log,
-		router: gin.New(),
-		server: &http.Server{
-			Addr: address,
-		},
-		close: make(chan struct{}),
+		log:       opts.Logger,
+		router:    gin.New(),
+		listeners: opts.Listeners,
+		errs:      make(chan error, len(opts.Listeners)),
 	}
 
-	r.server.Handler = r.router
+	// Allow values stored on the request context (e.g. the auth principal) to be read from
+	// the *gin.Context passed to collection hooks.
+	r.router.ContextWithFallback = true
+
+	// gin trusts forwarding headers from every peer by default, which lets clients choose the IP
+	// they are rate limited by
+	if err := r.router.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		return nil, err
+	}
 
 	r.router.NoMethod(methodNotAllowedHandler)
 	r.router.NoRoute(notFoundHandler)
 
-	r.router.Use(prepareKeys())
-	r.router.Use(gin.Recovery())
-	r.router.Use(r.loggingMiddleware())
+	r.router.Use(Middleware(opts)...)
+
+	return r, nil
+}
+
+// Middleware returns the middleware applied to every request: request IDs, access logging,
+// panic recovery, CORS and security headers. Create applies it to the engine it builds, it can
+// also be applied to routes registered on another engine.
+func Middleware(opts Opts) []gin.HandlerFunc {
+	handlers := []gin.HandlerFunc{
+		prepareKeys(),
+		requestIDMiddleware(),
+		// Logging wraps recovery so requests which panicked are logged with their 500
+		loggingMiddleware(opts.Logger),
+		gin.Recovery(),
+	}
+
+	if opts.CORS != nil {
+		handlers = append(handlers, corsMiddleware(*opts.CORS))
+	}
+
+	if opts.SecurityHeaders != nil {
+		handlers = append(handlers, securityHeadersMiddleware(*opts.SecurityHeaders))
+	}
 
-	return r
+	return handlers
 }
 
 func (r *HttpServer) Router() *gin.Engine {
 	return r.router
 }
 
-func (r *HttpServer) Run() {
-	r.log.Printf("Starting REST server on %s\n", r.server.Addr)
+// Start begins serving on every listener in the background. Errors binding addresses are
+// returned directly, errors occurring while serving are reported on Errors.
+func (r *HttpServer) Start() error {
+	var lns []net.Listener
 
-	go func() {
-		<-r.close
-		if err := r.server.Close(); err != nil {
-			log.Fatalf("Server Close Error: %v\n", err)
+	for _, l := range r.listeners {
+		srv, err := l.server(r.router, r.log)
+
+		if err == nil {
+			var ln net.Listener
+			ln, err = l.listen()
+			lns = append(lns, ln)
+			r.servers = append(r.servers, srv)
 		}
-	}()
 
-	if err := r.server.ListenAndServe(); err != nil {
-		if err == http.ErrServerClosed {
-			log.Println("HTTP Server closed")
-		} else {
-			log.Fatalf("HTTP Listen Error: %v\n", err)
+		if err != nil {
+			for _, ln := range lns {
+				if ln != nil {
+					ln.Close()
+				}
+			}
+
+			r.servers = nil
+
+			return err
 		}
 	}
+
+	for i, srv := range r.servers {
+		l, ln := r.listeners[i], lns[i]
+
+		r.log.Info("Starting REST server",
+			slog.String("network", l.network()),
+			slog.String("address", ln.Addr().String()),
+			slog.Bool("tls", l.TLS != nil),
+			slog.Bool("h2c", l.H2C && l.TLS == nil),
+		)
+
+		go func() {
+			var err error
+
+			if srv.TLSConfig != nil {
+				err = srv.ServeTLS(ln, "", "")
+			} else {
+				err = srv.Serve(ln)
+			}
+
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				r.errs <- err
+			}
+		}()
+	}
+
+	return nil
+}
+
+// Errors reports errors which stopped a listener.
+func (r *HttpServer) Errors() <-chan error {
+	return r.errs
 }
 
-func (r *HttpServer) Close() {
-	close(r.close)
+// Shutdown stops accepting connections and waits for in-flight requests to complete until ctx
+// is done, at which point remaining connections are closed.
+func (r *HttpServer) Shutdown(ctx context.Context) error {
+	errs := make([]error, len(r.servers))
+
+	var wg sync.WaitGroup
+
+	for i, srv := range r.servers {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := srv.Shutdown(ctx)
+
+			if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+				r.log.Warn("HTTP server shutdown timed out, closing remaining connections")
+				err = errors.Join(err, srv.Close())
+			}
+
+			errs[i] = err
+		}()
+	}
+
+	wg.Wait()
+
+	r.log.Info("HTTP server closed")
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,107 @@
+package scaffold
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexsobiek/scaffold/tracing"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrument wraps the collection hooks so their execution is timed and traced. Hooks are wrapped
+// once, later injections only swap the metrics and tracer the wrappers report to.
+func (c *C[T]) instrument() {
+	if c.instrumented || (c.metrics == nil && c.tracer == nil) {
+		return
+	}
+
+	c.instrumented = true
+
+	access, read, write, update, del := c.access, c.read, c.write, c.update, c.delete
+
+	c.access = func(ctx context.Context, id primitive.ObjectID) error {
+		ctx, done := c.hook(ctx, "access", id)
+		err := access(ctx, id)
+		done(err)
+		return err
+	}
+
+	c.read = func(ctx context.Context, id primitive.ObjectID, data *T) (*T, error) {
+		ctx, done := c.hook(ctx, "read", id)
+		d, err := read(ctx, id, data)
+		done(err)
+		return d, err
+	}
+
+	c.write = func(ctx context.Context, id primitive.ObjectID, data *T) (*T, error) {
+		ctx, done := c.hook(ctx, "write", id)
+		d, err := write(ctx, id, data)
+		done(err)
+		return d, err
+	}
+
+	c.update = func(ctx context.Context, id primitive.ObjectID, data *T, updates *bson.M) (*bson.M, error) {
+		ctx, done := c.hook(ctx, "update", id)
+		u, err := update(ctx, id, data, updates)
+		done(err)
+		return u, err
+	}
+
+	c.delete = func(ctx context.Context, id primitive.ObjectID) error {
+		ctx, done := c.hook(ctx, "delete", id)
+		err := del(ctx, id)
+		done(err)
+		return err
+	}
+}
+
+// hook instruments a hook invocation, returning the context to run it with and a function to be
+// called with its outcome.
+func (c *C[T]) hook(ctx context.Context, name string, id primitive.ObjectID) (context.Context, func(error)) {
+	start := time.Now()
+
+	var span trace.Span
+
+	if c.tracer != nil {
+		ctx, span = c.tracer.Start(ctx, "scaffold.hook."+name, trace.WithAttributes(
+			attribute.String("scaffold.collection", c.slug),
+			attribute.String("scaffold.document.id", id.Hex()),
+		))
+	}
+
+	return ctx, func(err error) {
+		c.metrics.ObserveHook(c.slug, name, time.Since(start))
+
+		if span != nil {
+			tracing.End(span, err)
+		}
+	}
+}
+
+// op instruments a Mongo operation made by the collection, returning the context to run it with
+// and a function to be called with its outcome.
+func (c *C[T]) op(ctx context.Context, name string) (context.Context, func(error)) {
+	start := time.Now()
+
+	var span trace.Span
+
+	if c.tracer != nil {
+		ctx, span = c.tracer.Start(ctx, "mongo."+name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+			semconv.DBSystemMongoDB,
+			semconv.DBCollectionName(c.mc.Name()),
+			semconv.DBOperationName(name),
+		))
+	}
+
+	return ctx, func(err error) {
+		c.metrics.ObserveMongo(c.slug, name, time.Since(start), err)
+
+		if span != nil {
+			tracing.End(span, err)
+		}
+	}
+}
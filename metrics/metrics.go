@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	collectionKey = "scaffold.metrics.collection"
+	operationKey  = "scaffold.metrics.operation"
+)
+
+// Metrics collects Prometheus metrics for HTTP requests, Mongo operations, connection pools and
+// collection hooks. A nil *Metrics records nothing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	mongo    *prometheus.HistogramVec
+	hooks    *prometheus.HistogramVec
+	pool     *prometheus.GaugeVec
+	poolWait *prometheus.CounterVec
+}
+
+// New creates metrics registered with registry. When registry is nil a new registry including
+// Go runtime and process collectors is created.
+func New(registry *prometheus.Registry) *Metrics {
+	if registry == nil {
+		registry = prometheus.NewRegistry()
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+
+	m := &Metrics{
+		registry: registry,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scaffold_http_requests_total",
+			Help: "HTTP requests handled, by collection, operation, route and status.",
+		}, []string{"collection", "operation", "route", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "scaffold_http_request_duration_seconds",
+			Help:    "HTTP request latency, by collection, operation, route and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"collection", "operation", "route", "status"}),
+		mongo: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "scaffold_mongo_operation_duration_seconds",
+			Help:    "Latency of Mongo operations made by collections, by collection, operation and outcome.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"collection", "operation", "outcome"}),
+		hooks: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "scaffold_hook_duration_seconds",
+			Help:    "Execution time of collection hooks, by collection and hook.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"collection", "hook"}),
+		pool: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "scaffold_mongo_pool_connections",
+			Help: "Mongo connection pool connections, by server address and state.",
+		}, []string{"address", "state"}),
+		poolWait: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "scaffold_mongo_pool_checkout_failures_total",
+			Help: "Failed Mongo connection checkouts, by server address and reason.",
+		}, []string{"address", "reason"}),
+	}
+
+	registry.MustRegister(m.requests, m.latency, m.mongo, m.hooks, m.pool, m.poolWait)
+
+	return m
+}
+
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// SetLabels labels the request with the collection and operation it performs.
+func SetLabels(c *gin.Context, collection string, operation string) {
+	c.Set(collectionKey, collection)
+	c.Set(operationKey, operation)
+}
+
+// Middleware records the count and latency of every request.
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		labels := prometheus.Labels{
+			"collection": c.GetString(collectionKey),
+			"operation":  c.GetString(operationKey),
+			"route":      c.FullPath(),
+			"status":     strconv.Itoa(c.Writer.Status()),
+		}
+
+		m.requests.With(labels).Inc()
+		m.latency.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveMongo records the latency of a Mongo operation.
+func (m *Metrics) ObserveMongo(collection string, operation string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	outcome := "success"
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		outcome = "not_found"
+	} else if err != nil {
+		outcome = "error"
+	}
+
+	m.mongo.WithLabelValues(collection, operation, outcome).Observe(d.Seconds())
+}
+
+// ObserveHook records the execution time of a collection hook.
+func (m *Metrics) ObserveHook(collection string, hook string, d time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.hooks.WithLabelValues(collection, hook).Observe(d.Seconds())
+}
+
+// PoolMonitor returns a monitor to be set on the Mongo client options, tracking pool statistics.
+func (m *Metrics) PoolMonitor() *event.PoolMonitor {
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				m.pool.WithLabelValues(e.Address, "open").Inc()
+			case event.ConnectionClosed:
+				m.pool.WithLabelValues(e.Address, "open").Dec()
+			case event.GetSucceeded:
+				m.pool.WithLabelValues(e.Address, "in_use").Inc()
+			case event.ConnectionReturned:
+				m.pool.WithLabelValues(e.Address, "in_use").Dec()
+			case event.GetFailed:
+				m.poolWait.WithLabelValues(e.Address, e.Reason).Inc()
+			}
+		},
+	}
+}
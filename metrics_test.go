@@ -0,0 +1,82 @@
+package scaffold
+
+import (
+	"context"
+	nethttp "net/http"
+	"testing"
+
+	"github.com/alexsobiek/scaffold/auth"
+)
+
+func TestMetrics(t *testing.T) {
+	notes := NewCollection(CollectionOpts[note]{Name: "Notes", Slug: "notes"})
+	s, h := testScaffold(t, ScaffoldOpts{
+		Collections: []Collection{notes},
+		APIKeys:     &APIKeyOpts{},
+		Metrics:     &MetricsOpts{},
+	})
+
+	ctx := context.Background()
+	scopes := []auth.Scope{{Collection: "notes", Operations: []auth.Operation{auth.OpAll}}}
+
+	_, admin, err := s.keys.Create(ctx, NewAPIKey{Name: "scraper", Roles: []string{"admin"}, Scopes: scopes})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, member, err := s.keys.Create(ctx, NewAPIKey{Name: "member", Scopes: scopes})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectStatus(t, request(h, "GET", "/metrics", nil), nethttp.StatusUnauthorized)
+	expectStatus(t, request(h, "GET", "/metrics", nil, "X-API-Key", member), nethttp.StatusForbidden)
+	expectStatus(t, request(h, "GET", "/metrics", nil, "X-API-Key", admin), nethttp.StatusOK)
+
+	t.Run("hooks are instrumented once", func(t *testing.T) {
+		// Injecting again, e.g. by mounting the collection twice, must not wrap the hooks again
+		notes.instrument()
+
+		doc, err := notes.Insert(ctx, note{Text: "hello"})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expectStatus(t, request(h, "GET", "/notes/"+doc.ID.Hex(), nil, "X-API-Key", member), nethttp.StatusOK)
+
+		families, err := s.metrics.Registry().Gather()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var observed uint64
+
+		for _, f := range families {
+			if f.GetName() != "scaffold_hook_duration_seconds" {
+				continue
+			}
+
+			for _, m := range f.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "hook" && l.GetValue() == "access" {
+						observed += m.GetHistogram().GetSampleCount()
+					}
+				}
+			}
+		}
+
+		if observed != 1 {
+			t.Fatalf("access hook observed %d times", observed)
+		}
+	})
+}
+
+func TestMetricsPublic(t *testing.T) {
+	_, h := testScaffold(t, ScaffoldOpts{Metrics: &MetricsOpts{Public: true}})
+
+	expectStatus(t, request(h, "GET", "/metrics", nil), nethttp.StatusOK)
+}
@@ -0,0 +1,415 @@
+package scaffold
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrationLockID is the _id of the lock document in the migrations collection.
+const migrationLockID = "_lock"
+
+// ErrMigrationLocked is returned when another instance holds the migration lock for longer than
+// LockTimeout.
+var ErrMigrationLocked = errors.New("migrations are locked by another instance")
+
+// Migration changes stored data, e.g. when the shape of a collection's type changes. Migrations
+// run in the order they are registered and are recorded by name, so each is applied once.
+type Migration struct {
+	Name string
+	Up   func(context.Context, *Database) error
+	// Down reverts Up. Migrations without Down cannot be rolled back.
+	Down func(context.Context, *Database) error
+}
+
+type MigrationOpts struct {
+	Migrations []Migration
+	// Collection records applied migrations and holds the lock, defaults to "_migrations".
+	Collection string
+	// Manual disables applying pending migrations on Start. They can then be applied through
+	// the admin API or the command line.
+	Manual bool
+	// LockTTL is how long the lock is held without being renewed before another instance may
+	// take it over, e.g. after a crash. Defaults to one minute.
+	LockTTL time.Duration
+	// LockTimeout is how long Up and Down wait for another instance to release the lock before
+	// failing, defaults to five minutes.
+	LockTimeout time.Duration
+	// AdminPath is where the migration endpoints are mounted, defaults to "/_admin/migrations".
+	AdminPath string
+	// AdminRole is the role required to use the endpoints, defaults to "admin".
+	AdminRole string
+}
+
+// MigrationStatus reports whether a registered migration has been applied.
+type MigrationStatus struct {
+	Name       string     `json:"name"`
+	Applied    *time.Time `json:"applied,omitempty"`
+	Reversible bool       `json:"reversible"`
+}
+
+type appliedMigration struct {
+	Name     string             `bson:"_id"`
+	Applied  primitive.DateTime `bson:"applied"`
+	Duration int64              `bson:"duration_ms"`
+}
+
+// Migrations applies and rolls back migrations. A lock in the migrations collection ensures
+// only one instance runs them at a time.
+type Migrations struct {
+	opts MigrationOpts
+	db   *Database
+	col  *mongo.Collection
+	log  *slog.Logger
+}
+
+func newMigrations(db *Database, opts MigrationOpts, log *slog.Logger) (*Migrations, error) {
+	if opts.Collection == "" {
+		opts.Collection = "_migrations"
+	}
+
+	if opts.LockTTL == 0 {
+		opts.LockTTL = time.Minute
+	}
+
+	if opts.LockTimeout == 0 {
+		opts.LockTimeout = 5 * time.Minute
+	}
+
+	if opts.AdminPath == "" {
+		opts.AdminPath = "/_admin/migrations"
+	}
+
+	if opts.AdminRole == "" {
+		opts.AdminRole = "admin"
+	}
+
+	names := map[string]bool{}
+
+	for _, m := range opts.Migrations {
+		if m.Name == "" || strings.HasPrefix(m.Name, "_") {
+			return nil, fmt.Errorf("invalid migration name %q", m.Name)
+		}
+
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %s has no Up function", m.Name)
+		}
+
+		if names[m.Name] {
+			return nil, fmt.Errorf("migration %s is registered twice", m.Name)
+		}
+
+		names[m.Name] = true
+	}
+
+	return &Migrations{opts: opts, db: db, col: db.Collection(opts.Collection), log: log}, nil
+}
+
+// Status lists the registered migrations in order.
+func (m *Migrations) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(m.opts.Migrations))
+
+	for i, mig := range m.opts.Migrations {
+		status[i] = MigrationStatus{Name: mig.Name, Reversible: mig.Down != nil}
+
+		if a, ok := applied[mig.Name]; ok {
+			t := a.Applied.Time()
+			status[i].Applied = &t
+		}
+	}
+
+	return status, nil
+}
+
+// Up applies pending migrations in order, returning the names of those applied. With dryRun,
+// it returns the pending migrations without applying them.
+func (m *Migrations) Up(ctx context.Context, dryRun bool) ([]string, error) {
+	ran := []string{}
+
+	err := m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.opts.Migrations {
+			if _, ok := applied[mig.Name]; ok {
+				continue
+			}
+
+			if !dryRun {
+				if err := m.run(ctx, mig.Name, mig.Up, true); err != nil {
+					return err
+				}
+			}
+
+			ran = append(ran, mig.Name)
+		}
+
+		return nil
+	})
+
+	return ran, err
+}
+
+// Down rolls back the last steps applied migrations in reverse order, returning the names of
+// those rolled back. With dryRun, it returns the migrations which would be rolled back.
+func (m *Migrations) Down(ctx context.Context, steps int, dryRun bool) ([]string, error) {
+	ran := []string{}
+
+	err := m.locked(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.opts.Migrations) - 1; i >= 0 && len(ran) < steps; i-- {
+			mig := m.opts.Migrations[i]
+
+			if _, ok := applied[mig.Name]; !ok {
+				continue
+			}
+
+			if mig.Down == nil {
+				return fmt.Errorf("migration %s cannot be rolled back", mig.Name)
+			}
+
+			if !dryRun {
+				if err := m.run(ctx, mig.Name, mig.Down, false); err != nil {
+					return err
+				}
+			}
+
+			ran = append(ran, mig.Name)
+		}
+
+		return nil
+	})
+
+	return ran, err
+}
+
+// run runs fn and records the migration as applied or rolled back.
+func (m *Migrations) run(ctx context.Context, name string, fn func(context.Context, *Database) error, up bool) error {
+	start := time.Now()
+
+	if err := fn(ctx, m.db); err != nil {
+		return fmt.Errorf("migration %s: %w", name, err)
+	}
+
+	var err error
+
+	if up {
+		_, err = m.col.InsertOne(ctx, appliedMigration{
+			Name:     name,
+			Applied:  primitive.NewDateTimeFromTime(time.Now()),
+			Duration: time.Since(start).Milliseconds(),
+		})
+	} else {
+		_, err = m.col.DeleteOne(ctx, bson.M{"_id": name})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	m.log.Info("Migration completed",
+		slog.String("migration", name),
+		slog.Bool("up", up),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return nil
+}
+
+func (m *Migrations) applied(ctx context.Context) (map[string]appliedMigration, error) {
+	cur, err := m.col.Find(ctx, bson.M{"_id": bson.M{"$ne": migrationLockID}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []appliedMigration
+
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	applied := map[string]appliedMigration{}
+
+	for _, d := range docs {
+		applied[d.Name] = d
+	}
+
+	return applied, nil
+}
+
+// locked runs fn while holding the lock, waiting up to LockTimeout for other instances to
+// release it. The lock is renewed while fn runs, and ctx passed to fn is cancelled if renewal
+// fails.
+func (m *Migrations) locked(ctx context.Context, fn func(context.Context) error) error {
+	owner := primitive.NewObjectID().Hex()
+	deadline := time.Now().Add(m.opts.LockTimeout)
+
+	for {
+		ok, err := m.lock(ctx, owner)
+
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return ErrMigrationLocked
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+
+	fnCtx, cancel := context.WithCancelCause(ctx)
+	renewing := make(chan struct{})
+
+	go func() {
+		defer close(renewing)
+
+		ticker := time.NewTicker(m.opts.LockTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-fnCtx.Done():
+				return
+			case <-ticker.C:
+				if ok, err := m.lock(fnCtx, owner); err != nil || !ok {
+					cancel(errors.Join(errors.New("lost migration lock"), err))
+					return
+				}
+			}
+		}
+	}()
+
+	// Renewal stops before the lock is released, so it cannot take the lock again afterwards
+	defer func() {
+		cancel(nil)
+		<-renewing
+
+		m.col.DeleteOne(context.WithoutCancel(ctx), bson.M{"_id": migrationLockID, "owner": owner})
+	}()
+
+	if err := fn(fnCtx); err != nil {
+		return errors.Join(err, context.Cause(fnCtx))
+	}
+
+	return nil
+}
+
+// lock takes or renews the lock for owner, reporting false when another owner holds it.
+func (m *Migrations) lock(ctx context.Context, owner string) (bool, error) {
+	now := time.Now()
+
+	_, err := m.col.UpdateOne(ctx,
+		bson.M{
+			"_id": migrationLockID,
+			"$or": bson.A{
+				bson.M{"owner": owner},
+				bson.M{"expires": bson.M{"$lt": primitive.NewDateTimeFromTime(now)}},
+			},
+		},
+		bson.M{"$set": bson.M{
+			"owner":   owner,
+			"expires": primitive.NewDateTimeFromTime(now.Add(m.opts.LockTTL)),
+		}},
+		options.Update().SetUpsert(true),
+	)
+
+	// The upsert conflicts with the lock document when another owner holds it
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (m *Migrations) inject(rg *gin.RouterGroup) {
+	rg.Use(auth.RequireRole(m.opts.AdminRole))
+
+	rg.GET("/", m.handleStatus)
+	rg.POST("/up", m.handleUp)
+	rg.POST("/down", m.handleDown)
+}
+
+func (m *Migrations) handleStatus(ctx *gin.Context) {
+	status, err := m.Status(ctx)
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	http.Ok(ctx, status)
+}
+
+// handleUp applies pending migrations, or lists them with ?dry_run=true.
+func (m *Migrations) handleUp(ctx *gin.Context) {
+	// Migrations run to completion even if the client disconnects
+	ran, err := m.Up(context.WithoutCancel(ctx.Request.Context()), ctx.Query("dry_run") == "true")
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	http.Ok(ctx, gin.H{"migrations": ran})
+}
+
+// handleDown rolls back ?steps=n migrations, defaulting to one, or lists them with
+// ?dry_run=true.
+func (m *Migrations) handleDown(ctx *gin.Context) {
+	steps := 1
+
+	if ctx.Query("steps") != "" {
+		var err error
+		steps, err = strconv.Atoi(ctx.Query("steps"))
+
+		if err != nil || steps < 1 {
+			http.BadRequest(ctx, errors.New("steps must be greater than 0"))
+			return
+		}
+	}
+
+	ran, err := m.Down(context.WithoutCancel(ctx.Request.Context()), steps, ctx.Query("dry_run") == "true")
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	http.Ok(ctx, gin.H{"migrations": ran})
+}
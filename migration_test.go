@@ -0,0 +1,58 @@
+package scaffold
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMigrationLock(t *testing.T) {
+	s, _ := testScaffold(t, ScaffoldOpts{Migrations: &MigrationOpts{
+		Migrations: []Migration{{
+			Name: "seed",
+			Up:   func(context.Context, *Database) error { return nil },
+		}},
+		Manual:      true,
+		LockTimeout: time.Millisecond,
+	}})
+
+	ctx := context.Background()
+	col := s.Database().Collection("_migrations")
+
+	// Another instance holds the lock
+	_, err := col.InsertOne(ctx, bson.M{
+		"_id":     migrationLockID,
+		"owner":   "other",
+		"expires": primitive.NewDateTimeFromTime(time.Now().Add(time.Hour)),
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Migrations().Up(ctx, false); !errors.Is(err, ErrMigrationLocked) {
+		t.Fatalf("expected the lock to time out, got %v", err)
+	}
+
+	if _, err := col.DeleteOne(ctx, bson.M{"_id": migrationLockID}); err != nil {
+		t.Fatal(err)
+	}
+
+	ran, err := s.Migrations().Up(ctx, false)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ran) != 1 || ran[0] != "seed" {
+		t.Fatalf("applied %v", ran)
+	}
+
+	if n, err := col.CountDocuments(ctx, bson.M{"_id": migrationLockID}); err != nil || n != 0 {
+		t.Fatalf("lock was not released: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,437 @@
+package scaffold
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/alexsobiek/scaffold/query"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	oidcStateCookie = "scaffold_oidc_state"
+	oidcLoginTTL    = 10 * time.Minute
+)
+
+type OIDCOpts struct {
+	// Issuer is the issuer URL, its discovery document is read from
+	// <Issuer>/.well-known/openid-configuration.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the absolute URL of the callback endpoint registered with the issuer.
+	RedirectURL string
+	// Scopes requested in addition to "openid", defaults to "profile" and "email".
+	Scopes []string
+	// Path is where the login and callback endpoints are mounted, defaults to "/auth/oidc".
+	Path string
+	// Collection holds pending logins, defaults to "_oidc_logins".
+	Collection string
+	// RolesClaim is the ID token claim holding the user's roles or groups, defaults to "roles".
+	RolesClaim string
+	// RoleMap maps values of RolesClaim to Scaffold roles. When nil, claim values are used as-is.
+	RoleMap map[string][]string
+	// DefaultRoles are granted to every user logging in through the issuer.
+	DefaultRoles []string
+	// HTTPClient is used for discovery, token and JWKS requests, defaults to http.DefaultClient.
+	HTTPClient *nethttp.Client
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcLogin is an authorization request awaiting its callback.
+type oidcLogin struct {
+	State    string             `bson:"state"`
+	Verifier string             `bson:"verifier"`
+	Nonce    string             `bson:"nonce"`
+	Redirect string             `bson:"redirect"`
+	Expires  primitive.DateTime `bson:"expires"`
+}
+
+// OIDC is an OpenID Connect relying party using the authorization code flow with PKCE.
+type OIDC struct {
+	opts      OIDCOpts
+	discovery oidcDiscovery
+	logins    *C[oidcLogin]
+	sessions  *sessions
+
+	// cookiePath is the path the endpoints are mounted at, including any prefix
+	cookiePath string
+
+	mu       sync.RWMutex
+	verifier *auth.JWTVerifier
+}
+
+func newOIDC(ctx context.Context, db *Database, opts OIDCOpts, sessions *sessions) (*OIDC, error) {
+	if opts.Path == "" {
+		opts.Path = "/auth/oidc"
+	}
+
+	if opts.Collection == "" {
+		opts.Collection = "_oidc_logins"
+	}
+
+	if opts.Scopes == nil {
+		opts.Scopes = []string{"profile", "email"}
+	}
+
+	if opts.RolesClaim == "" {
+		opts.RolesClaim = "roles"
+	}
+
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = nethttp.DefaultClient
+	}
+
+	o := &OIDC{opts: opts, sessions: sessions}
+
+	err := o.getJSON(ctx, strings.TrimSuffix(opts.Issuer, "/")+"/.well-known/openid-configuration", &o.discovery)
+
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+
+	if o.discovery.Issuer != opts.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch, got %s", o.discovery.Issuer)
+	}
+
+	if err := o.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	o.logins = NewCollection(CollectionOpts[oidcLogin]{
+		Name: "OIDC Logins",
+		Slug: opts.Collection,
+	})
+
+	o.logins.mc = db.Collection(opts.Collection)
+
+	_, err = o.logins.mc.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "state", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// refreshKeys fetches the issuer's JWKS, replacing the keys used to verify ID tokens.
+func (o *OIDC) refreshKeys(ctx context.Context) error {
+	var set auth.JWKS
+
+	if err := o.getJSON(ctx, o.discovery.JWKSURI, &set); err != nil {
+		return fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	v, err := auth.NewJWTVerifier(auth.JWTOpts{
+		Issuer:     o.discovery.Issuer,
+		Audience:   o.opts.ClientID,
+		RolesClaim: o.opts.RolesClaim,
+		Leeway:     time.Minute,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := v.AddJWKS(&set); err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	o.verifier = v
+	o.mu.Unlock()
+
+	return nil
+}
+
+// verify validates an ID token, refreshing the issuer's keys once in case they were rotated.
+func (o *OIDC) verify(ctx context.Context, raw string) (*auth.Principal, error) {
+	o.mu.RLock()
+	v := o.verifier
+	o.mu.RUnlock()
+
+	p, err := v.Verify(raw)
+
+	if err == nil {
+		return p, nil
+	}
+
+	if err := o.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	o.mu.RLock()
+	v = o.verifier
+	o.mu.RUnlock()
+
+	return v.Verify(raw)
+}
+
+// principal maps verified ID token claims to a principal.
+func (o *OIDC) principal(claims *auth.Principal) *auth.Principal {
+	roles := append([]string{}, o.opts.DefaultRoles...)
+
+	for _, r := range claims.Roles {
+		if o.opts.RoleMap == nil {
+			roles = append(roles, r)
+		} else {
+			roles = append(roles, o.opts.RoleMap[r]...)
+		}
+	}
+
+	return &auth.Principal{
+		Subject: claims.Subject,
+		Method:  "oidc",
+		Roles:   roles,
+		Claims:  claims.Claims,
+	}
+}
+
+func (o *OIDC) inject(rg *gin.RouterGroup) {
+	o.cookiePath = rg.BasePath()
+
+	rg.GET("/login", o.handleLogin)
+	rg.GET("/callback", o.handleCallback)
+}
+
+func (o *OIDC) handleLogin(ctx *gin.Context) {
+	state, err := randomToken()
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	verifier, err := randomToken()
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	nonce, err := randomToken()
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	// Only allow local redirects after login
+	redirect := ctx.Query("redirect")
+
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		redirect = "/"
+	}
+
+	_, err = o.logins.Insert(ctx, oidcLogin{
+		State:    hashToken(state),
+		Verifier: verifier,
+		Nonce:    nonce,
+		Redirect: redirect,
+		Expires:  primitive.NewDateTimeFromTime(time.Now().Add(oidcLoginTTL)),
+	})
+
+	if err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {o.opts.ClientID},
+		"redirect_uri":          {o.opts.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, o.opts.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	ctx.SetSameSite(nethttp.SameSiteLaxMode)
+	ctx.SetCookie(oidcStateCookie, state, int(oidcLoginTTL.Seconds()), o.cookiePath, "", o.sessions.opts.CookieSecure, true)
+
+	ctx.Redirect(nethttp.StatusFound, o.discovery.AuthorizationEndpoint+"?"+q.Encode())
+}
+
+func (o *OIDC) handleCallback(ctx *gin.Context) {
+	if e := ctx.Query("error"); e != "" {
+		http.Unauthorized(ctx, http.ErrUnauthorized{Message: "login failed: " + e})
+		return
+	}
+
+	state := ctx.Query("state")
+	cookie, _ := ctx.Cookie(oidcStateCookie)
+
+	ctx.SetCookie(oidcStateCookie, "", -1, o.cookiePath, "", o.sessions.opts.CookieSecure, true)
+
+	if state == "" || state != cookie {
+		http.BadRequest(ctx, errors.New("invalid state"))
+		return
+	}
+
+	login, err := o.logins.Find(ctx, &query.Comparison{Operator: query.Equal, Field: "state", Value: hashToken(state)})
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.BadRequest(ctx, errors.New("invalid state"))
+		} else {
+			http.Error(ctx, err)
+		}
+		return
+	}
+
+	// A login can only be completed once
+	if err := login.Delete(ctx); err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	if login.Data.Expires.Time().Before(time.Now()) {
+		http.BadRequest(ctx, errors.New("login expired"))
+		return
+	}
+
+	idToken, err := o.exchange(ctx, ctx.Query("code"), login.Data.Verifier)
+
+	if err != nil {
+		http.Unauthorized(ctx, http.ErrUnauthorized{Message: "token exchange failed"})
+		return
+	}
+
+	claims, err := o.verify(ctx, idToken)
+
+	if err != nil {
+		http.Unauthorized(ctx, err)
+		return
+	}
+
+	if nonce, _ := claims.Claims["nonce"].(string); nonce != login.Data.Nonce {
+		http.Unauthorized(ctx, http.ErrUnauthorized{Message: "invalid nonce"})
+		return
+	}
+
+	if err := o.sessions.start(ctx, o.principal(claims)); err != nil {
+		http.Error(ctx, err)
+		return
+	}
+
+	ctx.Redirect(nethttp.StatusFound, login.Data.Redirect)
+}
+
+// exchange redeems an authorization code at the token endpoint, returning the ID token.
+func (o *OIDC) exchange(ctx context.Context, code string, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.opts.RedirectURL},
+		"client_id":     {o.opts.ClientID},
+		"code_verifier": {verifier},
+	}
+
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodPost, o.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if o.opts.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(o.opts.ClientID), url.QueryEscape(o.opts.ClientSecret))
+	}
+
+	res, err := o.opts.HTTPClient.Do(req)
+
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != nethttp.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s", res.Status)
+	}
+
+	var body struct {
+		IDToken string `json:"id_token"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", err
+	}
+
+	if body.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+
+	return body.IDToken, nil
+}
+
+func (o *OIDC) getJSON(ctx context.Context, u string, v any) error {
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, u, nil)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	res, err := o.opts.HTTPClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != nethttp.StatusOK {
+		return fmt.Errorf("%s returned %s", u, res.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
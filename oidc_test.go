@@ -0,0 +1,398 @@
+package scaffold
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	nethttp "net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// testIssuer is a stand-in OpenID Connect issuer supporting the authorization code flow with
+// PKCE.
+type testIssuer struct {
+	*httptest.Server
+	t   *testing.T
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]testAuthorization
+	// claims adjusts the claims of issued ID tokens
+	claims func(jwt.MapClaims)
+	// signer signs issued ID tokens instead of key
+	signer *rsa.PrivateKey
+}
+
+type testAuthorization struct {
+	challenge string
+	nonce     string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := &testIssuer{t: t, key: key, codes: map[string]testAuthorization{}}
+
+	mux := nethttp.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                i.URL,
+			AuthorizationEndpoint: i.URL + "/authorize",
+			TokenEndpoint:         i.URL + "/token",
+			JWKSURI:               i.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		json.NewEncoder(w).Encode(auth.JWKS{Keys: []auth.JWK{{
+			Kty: "RSA",
+			Kid: "test",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", i.handleToken)
+
+	i.Server = httptest.NewServer(mux)
+	t.Cleanup(i.Close)
+
+	return i
+}
+
+// authorize approves the authorization request the relying party redirected to, returning the
+// code the issuer would redirect back with.
+func (i *testIssuer) authorize(location string) string {
+	u, err := url.Parse(location)
+
+	if err != nil {
+		i.t.Fatal(err)
+	}
+
+	q := u.Query()
+
+	if u.Path != "/authorize" || q.Get("response_type") != "code" || q.Get("client_id") != "scaffold" ||
+		q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" || q.Get("state") == "" ||
+		!strings.Contains(q.Get("scope"), "openid") {
+		i.t.Fatalf("unexpected authorization request %s", location)
+	}
+
+	code := mustToken(i.t)
+
+	i.mu.Lock()
+	i.codes[code] = testAuthorization{challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	i.mu.Unlock()
+
+	return code
+}
+
+func (i *testIssuer) handleToken(w nethttp.ResponseWriter, r *nethttp.Request) {
+	if err := r.ParseForm(); err != nil {
+		nethttp.Error(w, err.Error(), nethttp.StatusBadRequest)
+		return
+	}
+
+	id, secret, _ := r.BasicAuth()
+
+	if id != "scaffold" || secret != "secret" {
+		nethttp.Error(w, "invalid_client", nethttp.StatusUnauthorized)
+		return
+	}
+
+	i.mu.Lock()
+	a, ok := i.codes[r.Form.Get("code")]
+	delete(i.codes, r.Form.Get("code"))
+	i.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+
+	if !ok || r.Form.Get("grant_type") != "authorization_code" || base64.RawURLEncoding.EncodeToString(sum[:]) != a.challenge {
+		nethttp.Error(w, "invalid_grant", nethttp.StatusBadRequest)
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":    i.URL,
+		"aud":    "scaffold",
+		"sub":    "user-1",
+		"nonce":  a.nonce,
+		"iat":    time.Now().Unix(),
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"groups": []string{"engineering", "unmapped"},
+	}
+
+	if i.claims != nil {
+		i.claims(claims)
+	}
+
+	key := i.key
+
+	if i.signer != nil {
+		key = i.signer
+	}
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = "test"
+
+	raw, err := tok.SignedString(key)
+
+	if err != nil {
+		nethttp.Error(w, err.Error(), nethttp.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"id_token": raw, "token_type": "Bearer"})
+}
+
+func mustToken(t *testing.T) string {
+	tok, err := randomToken()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tok
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	tests := []struct {
+		name   string
+		issuer string
+	}{
+		{"issuer mismatch", issuer.URL + "/other"},
+		{"unreachable", "http://127.0.0.1:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newOIDC(context.Background(), nil, OIDCOpts{Issuer: tt.issuer, ClientID: "scaffold"}, nil)
+
+			if err == nil {
+				t.Fatal("expected discovery to fail")
+			}
+		})
+	}
+}
+
+func TestOIDC(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	_, h := testScaffold(t, ScaffoldOpts{
+		// Accounts serves /auth/me
+		Accounts: &AccountOpts{DisableRegistration: true},
+		OIDC: &OIDCOpts{
+			Issuer:       issuer.URL,
+			ClientID:     "scaffold",
+			ClientSecret: "secret",
+			RedirectURL:  "http://localhost/auth/oidc/callback",
+			RolesClaim:   "groups",
+			RoleMap:      map[string][]string{"engineering": {"editor"}},
+			DefaultRoles: []string{"member"},
+		},
+	})
+
+	// start begins a login, returning the state, the code the issuer approved it with and the
+	// state cookie
+	start := func(t *testing.T, redirect string) (string, string, string) {
+		t.Helper()
+
+		w := request(h, "GET", "/auth/oidc/login?redirect="+url.QueryEscape(redirect), nil)
+		expectStatus(t, w, nethttp.StatusFound)
+
+		state, ok := cookie(w, oidcStateCookie)
+
+		if !ok || !state.HttpOnly {
+			t.Fatal("login did not set an http-only state cookie")
+		}
+
+		location := w.Header().Get("Location")
+
+		if !strings.HasPrefix(location, issuer.URL) {
+			t.Fatalf("login redirected to %s", location)
+		}
+
+		loc, _ := url.Parse(location)
+
+		if loc.Query().Get("state") != state.Value {
+			t.Fatal("state cookie does not match the authorization request")
+		}
+
+		return state.Value, issuer.authorize(location), state.String()
+	}
+
+	callback := func(state string, code string, headers ...string) *httptest.ResponseRecorder {
+		return request(h, "GET", "/auth/oidc/callback?"+url.Values{"state": {state}, "code": {code}}.Encode(), nil, headers...)
+	}
+
+	t.Run("login", func(t *testing.T) {
+		state, code, stateCookie := start(t, "/dashboard")
+
+		w := callback(state, code, "Cookie", stateCookie)
+		expectStatus(t, w, nethttp.StatusFound)
+
+		if loc := w.Header().Get("Location"); loc != "/dashboard" {
+			t.Fatalf("redirected to %s after login", loc)
+		}
+
+		session, ok := cookie(w, "scaffold_session")
+
+		if !ok {
+			t.Fatal("login did not start a session")
+		}
+
+		me := request(h, "GET", "/auth/me", nil, "Cookie", session.String())
+		expectStatus(t, me, nethttp.StatusOK)
+
+		var res struct {
+			Data auth.Principal `json:"data"`
+		}
+
+		if err := json.Unmarshal(me.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+
+		roles := append([]string{}, res.Data.Roles...)
+		sort.Strings(roles)
+
+		if res.Data.Subject != "user-1" || res.Data.Method != "oidc" || strings.Join(roles, ",") != "editor,member" {
+			t.Fatalf("unexpected principal %+v", res.Data)
+		}
+
+		// The state can only be used once
+		expectStatus(t, callback(state, code, "Cookie", stateCookie), nethttp.StatusBadRequest)
+	})
+
+	t.Run("only local redirects", func(t *testing.T) {
+		for _, redirect := range []string{"https://evil.example", "//evil.example", "/\\evil.example"} {
+			state, code, stateCookie := start(t, redirect)
+
+			w := callback(state, code, "Cookie", stateCookie)
+			expectStatus(t, w, nethttp.StatusFound)
+
+			if loc := w.Header().Get("Location"); loc != "/" {
+				t.Fatalf("redirected to %s after login", loc)
+			}
+		}
+	})
+
+	tests := []struct {
+		name   string
+		setup  func()
+		modify func(state string, code string, stateCookie string) (string, string, string)
+		status int
+	}{
+		{
+			name: "state mismatch",
+			modify: func(state string, code string, stateCookie string) (string, string, string) {
+				other, _, _ := start(t, "/")
+				return other, code, stateCookie
+			},
+			status: nethttp.StatusBadRequest,
+		},
+		{
+			name: "missing state cookie",
+			modify: func(state string, code string, _ string) (string, string, string) {
+				return state, code, oidcStateCookie + "="
+			},
+			status: nethttp.StatusBadRequest,
+		},
+		{
+			name: "unknown code",
+			modify: func(state string, _ string, stateCookie string) (string, string, string) {
+				return state, "forged", stateCookie
+			},
+			status: nethttp.StatusUnauthorized,
+		},
+		{
+			name: "wrong code verifier",
+			modify: func(state string, code string, stateCookie string) (string, string, string) {
+				issuer.mu.Lock()
+				a := issuer.codes[code]
+				a.challenge = "challenge-of-another-verifier"
+				issuer.codes[code] = a
+				issuer.mu.Unlock()
+
+				return state, code, stateCookie
+			},
+			status: nethttp.StatusUnauthorized,
+		},
+		{
+			name:   "nonce mismatch",
+			setup:  func() { issuer.claims = func(c jwt.MapClaims) { c["nonce"] = "replayed" } },
+			status: nethttp.StatusUnauthorized,
+		},
+		{
+			name:   "wrong audience",
+			setup:  func() { issuer.claims = func(c jwt.MapClaims) { c["aud"] = "another-client" } },
+			status: nethttp.StatusUnauthorized,
+		},
+		{
+			name:   "wrong issuer",
+			setup:  func() { issuer.claims = func(c jwt.MapClaims) { c["iss"] = "https://evil.example" } },
+			status: nethttp.StatusUnauthorized,
+		},
+		{
+			name:   "expired token",
+			setup:  func() { issuer.claims = func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() } },
+			status: nethttp.StatusUnauthorized,
+		},
+		{
+			name: "invalid signature",
+			setup: func() {
+				key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				issuer.signer = key
+			},
+			status: nethttp.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer.claims, issuer.signer = nil, nil
+
+			if tt.setup != nil {
+				tt.setup()
+			}
+
+			state, code, stateCookie := start(t, "/")
+
+			if tt.modify != nil {
+				state, code, stateCookie = tt.modify(state, code, stateCookie)
+			}
+
+			w := callback(state, code, "Cookie", stateCookie)
+			expectStatus(t, w, tt.status)
+
+			if _, ok := cookie(w, "scaffold_session"); ok {
+				t.Fatal("rejected login started a session")
+			}
+		})
+	}
+
+	t.Run("issuer error", func(t *testing.T) {
+		expectStatus(t, request(h, "GET", "/auth/oidc/callback?error=access_denied", nil), nethttp.StatusUnauthorized)
+	})
+}
@@ -0,0 +1,306 @@
+package scaffold
+
+import (
+	"encoding/json"
+	nethttp "net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/alexsobiek/scaffold/openapi"
+	"github.com/alexsobiek/scaffold/query"
+	"github.com/gin-gonic/gin"
+)
+
+type OpenAPIOpts struct {
+	// Path is where the document is served, defaults to "/openapi.json".
+	Path string
+	// Title defaults to "Scaffold" and Version to "1.0.0".
+	Title       string
+	Version     string
+	Description string
+	// UI serves interactive documentation at UIPath when set.
+	UI openapi.UI
+	// UIPath defaults to "/docs".
+	UIPath string
+}
+
+// OpenAPI returns an OpenAPI 3.1 document describing the collection routes. It can be called
+// before Start, e.g. to write the document at build time.
+func (s *Scaffold) OpenAPI() *openapi.Spec {
+	opts := OpenAPIOpts{Title: "Scaffold", Version: "1.0.0"}
+
+	if s.opts.OpenAPI != nil {
+		opts = *s.opts.OpenAPI
+	}
+
+	spec := openapi.New(openapi.Info{
+		Title:       opts.Title,
+		Version:     opts.Version,
+		Description: opts.Description,
+	})
+
+	if base := s.basePath(); base != "/" {
+		spec.Servers = []openapi.Server{{URL: base}}
+	}
+
+	if s.opts.JWT != nil {
+		spec.AddSecurityScheme("bearer", &openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"})
+	}
+
+	if s.opts.APIKeys != nil {
+		header := s.opts.APIKeys.Header
+
+		if header == "" {
+			header = "X-API-Key"
+		}
+
+		spec.AddSecurityScheme("apiKey", &openapi.SecurityScheme{Type: "apiKey", In: "header", Name: header})
+	}
+
+	if s.opts.Accounts != nil || s.opts.OIDC != nil || s.opts.Sessions != nil {
+		cookie := "scaffold_session"
+
+		if s.opts.Sessions != nil && s.opts.Sessions.CookieName != "" {
+			cookie = s.opts.Sessions.CookieName
+		}
+
+		spec.AddSecurityScheme("session", &openapi.SecurityScheme{Type: "apiKey", In: "cookie", Name: cookie})
+	}
+
+	for _, c := range s.opts.Collections {
+		c.describe(spec, s.opts.RequireAuth)
+	}
+
+	return spec
+}
+
+// injectOpenAPI serves the document, built on first request once all collections are registered.
+func (s *Scaffold) injectOpenAPI() error {
+	opts := *s.opts.OpenAPI
+
+	var once sync.Once
+	var doc []byte
+	var err error
+
+	s.router.GET(opts.Path, func(c *gin.Context) {
+		once.Do(func() {
+			doc, err = json.Marshal(s.OpenAPI())
+		})
+
+		if err != nil {
+			http.Error(c, err)
+			return
+		}
+
+		c.Data(nethttp.StatusOK, "application/json", doc)
+	})
+
+	if opts.UI == "" {
+		return nil
+	}
+
+	ui, err := opts.UI.Handler(opts.Title, path.Join(s.basePath(), opts.Path))
+
+	if err != nil {
+		return err
+	}
+
+	s.router.GET(opts.UIPath, ui)
+
+	return nil
+}
+
+// basePath returns the path prefix routes are registered under, "/" unless mounted on a group.
+func (s *Scaffold) basePath() string {
+	if r, ok := s.router.(interface{ BasePath() string }); ok {
+		return r.BasePath()
+	}
+
+	return "/"
+}
+
+// describe adds the routes of the collection to spec.
+func (c *C[T]) describe(spec *openapi.Spec, requireAuth bool) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	data := spec.Schema(t)
+	name := spec.Name(t)
+
+	if name == "" {
+		name = strings.ReplaceAll(c.slug, "/", "_")
+	}
+
+	doc := spec.Define(name+"Document", &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"id":           openapi.ObjectID(),
+			"created":      openapi.DateTime(),
+			"last_updated": openapi.DateTime(),
+			"document":     data,
+		},
+		Required: []string{"id", "created", "last_updated", "document"},
+	})
+
+	envelope := &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"data": doc,
+		},
+		Required: []string{"data"},
+	}
+
+	var security []openapi.SecurityRequirement
+
+	if requireAuth || c.permissions != nil {
+		security = spec.SecurityRequirements()
+	}
+
+	base := path.Join("/", c.slug)
+	idParam := openapi.Parameter{Name: "id", In: "path", Required: true, Schema: openapi.ObjectID()}
+
+	responses := func(ok string, res *openapi.Response, errs ...string) map[string]*openapi.Response {
+		r := map[string]*openapi.Response{ok: res}
+
+		for _, e := range append(errs, "TooManyRequests", "InternalError") {
+			r[errorStatus[e]] = openapi.ResponseRef(e)
+		}
+
+		if security != nil {
+			r["401"] = openapi.ResponseRef("Unauthorized")
+			r["403"] = openapi.ResponseRef("Forbidden")
+		}
+
+		return r
+	}
+
+	op := func(o auth.Operation, summary string) *openapi.Operation {
+		return &openapi.Operation{
+			OperationID: string(o) + "_" + strings.ReplaceAll(strings.Trim(c.slug, "/"), "/", "_"),
+			Summary:     summary,
+			Tags:        []string{c.name},
+			Security:    security,
+		}
+	}
+
+	create := op(auth.OpCreate, "Create a document in "+c.name)
+	create.RequestBody = &openapi.RequestBody{
+		Required: true,
+		Content:  map[string]openapi.MediaType{"application/json": {Schema: data}},
+	}
+	create.Responses = responses("201", openapi.JSON("The created document", envelope), "BadRequest")
+	spec.Add("POST", base+"/", create)
+
+	filters, sortable := c.filterParameters(spec)
+
+	list := op(auth.OpList, "List documents in "+c.name)
+	list.Parameters = append([]openapi.Parameter{
+		{Name: "limit", In: "query", Description: "Documents per page", Schema: &openapi.Schema{Type: "integer", Minimum: ptr(1.0), Default: 10}},
+		{Name: "page", In: "query", Description: "Page number, starting at 1", Schema: &openapi.Schema{Type: "integer", Minimum: ptr(1.0), Default: 1}},
+		{Name: "sort", In: "query", Description: "Comma separated fields to sort by, descending when prefixed with \"-\": " + sortable, Schema: &openapi.Schema{Type: "string"}},
+	}, filters...)
+	list.Responses = responses("200", openapi.JSON("A page of documents", &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"data":  {Type: "array", Items: doc},
+			"count": {Type: "integer"},
+			"page":  {Type: "integer"},
+		},
+		Required: []string{"data", "count", "page"},
+	}), "BadRequest")
+	spec.Add("GET", base+"/", list)
+
+	changes := op(auth.OpList, "Stream changes to documents in "+c.name)
+	changes.OperationID = "changes_" + strings.ReplaceAll(strings.Trim(c.slug, "/"), "/", "_")
+	changes.Description = "Server-Sent Events named create, update or delete, whose data is the document or, for deletions, its id. Accepts the same filters as listing documents."
+	changes.Parameters = append([]openapi.Parameter{
+		{Name: "Last-Event-ID", In: "header", Description: "Resume after this event", Schema: &openapi.Schema{Type: "string"}},
+	}, filters...)
+	changes.Responses = responses("200", &openapi.Response{
+		Description: "A stream of changes",
+		Content:     map[string]openapi.MediaType{"text/event-stream": {Schema: &openapi.Schema{Type: "string"}}},
+	}, "BadRequest")
+	spec.Add("GET", base+"/_changes", changes)
+
+	read := op(auth.OpRead, "Get a document from "+c.name)
+	read.Parameters = []openapi.Parameter{idParam}
+	read.Responses = responses("200", openapi.JSON("The document", envelope), "BadRequest", "NotFound")
+	spec.Add("GET", base+"/:id", read)
+
+	update := op(auth.OpUpdate, "Update fields of a document in "+c.name)
+	update.Parameters = []openapi.Parameter{idParam}
+	update.RequestBody = &openapi.RequestBody{
+		Required: true,
+		Content: map[string]openapi.MediaType{"application/json": {Schema: &openapi.Schema{
+			Type:        "object",
+			Description: "Fields to set, keyed by their bson or Go field name",
+		}}},
+	}
+	update.Responses = responses("200", openapi.JSON("The updated document", envelope), "BadRequest", "NotFound")
+	spec.Add("PATCH", base+"/:id", update)
+
+	if c.deletable {
+		del := op(auth.OpDelete, "Delete a document from "+c.name)
+		del.Parameters = []openapi.Parameter{idParam}
+		del.Responses = responses("200", openapi.JSON("The deleted document", envelope), "BadRequest", "NotFound")
+		spec.Add("DELETE", base+"/:id", del)
+	}
+
+	for _, route := range c.routes {
+		spec.Add(route.Method, path.Join(base, route.Path), &openapi.Operation{
+			Summary:   route.Method + " " + path.Join(base, route.Path),
+			Tags:      []string{c.name},
+			Security:  security,
+			Responses: map[string]*openapi.Response{"default": {Description: "Response"}},
+		})
+	}
+}
+
+// filterParameters describes the query parameters filtering on each field clients may filter
+// on, also returning the field names for describing sort.
+func (c *C[T]) filterParameters(spec *openapi.Spec) ([]openapi.Parameter, string) {
+	var names, ops []string
+
+	for name := range c.fields {
+		names = append(names, name)
+	}
+
+	for op := range query.Operators {
+		if op != "eq" {
+			ops = append(ops, op)
+		}
+	}
+
+	sort.Strings(names)
+	sort.Strings(ops)
+
+	params := make([]openapi.Parameter, len(names))
+
+	for i, name := range names {
+		params[i] = openapi.Parameter{
+			Name:        name,
+			In:          "query",
+			Description: "Matches documents whose " + name + " equals the value. Use " + name + "[op] to compare with one of " + strings.Join(ops, ", ") + " instead, where in and nin take comma separated values.",
+			Schema:      spec.Schema(c.fields[name].Type),
+		}
+	}
+
+	return params, strings.Join(names, ", ")
+}
+
+// errorStatus maps error response components to their status code.
+var errorStatus = map[string]string{
+	"BadRequest":      "400",
+	"Unauthorized":    "401",
+	"Forbidden":       "403",
+	"NotFound":        "404",
+	"TooManyRequests": "429",
+	"InternalError":   "500",
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
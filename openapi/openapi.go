@@ -0,0 +1,214 @@
+// Package openapi builds OpenAPI 3.1 documents describing a Scaffold API.
+package openapi
+
+import (
+	"sort"
+	"strings"
+)
+
+const Version = "3.1.0"
+
+type Spec struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+
+	names map[any]string
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem maps lower case HTTP methods to the operation they perform.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	Responses       map[string]*Response       `json:"responses,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// SecurityRequirement maps security scheme names to the scopes required.
+type SecurityRequirement map[string][]string
+
+// New creates a document with the error envelope and standard error responses defined.
+func New(info Info) *Spec {
+	s := &Spec{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas:   map[string]*Schema{},
+			Responses: map[string]*Response{},
+		},
+		names: map[any]string{},
+	}
+
+	s.Define("Error", &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"error":      {Type: "string"},
+			"request_id": {Type: "string"},
+		},
+		Required: []string{"error"},
+	})
+
+	for name, desc := range map[string]string{
+		"BadRequest":      "The request is invalid",
+		"Unauthorized":    "The request is not authenticated",
+		"Forbidden":       "The principal may not perform the operation",
+		"NotFound":        "The document does not exist",
+		"TooManyRequests": "The rate limit is exceeded",
+		"InternalError":   "An unexpected error occurred",
+	} {
+		s.Components.Responses[name] = JSON(desc, Ref("Error"))
+	}
+
+	return s
+}
+
+// Add documents the operation performed by method on path. Gin path parameters (":id", "*path")
+// are converted to OpenAPI templates.
+func (s *Spec) Add(method string, path string, op *Operation) {
+	path = templatePath(path)
+
+	item, ok := s.Paths[path]
+
+	if !ok {
+		item = PathItem{}
+		s.Paths[path] = item
+	}
+
+	item[strings.ToLower(method)] = op
+
+	for _, tag := range op.Tags {
+		s.addTag(tag)
+	}
+}
+
+// Define registers a named schema component, returning a reference to it.
+func (s *Spec) Define(name string, schema *Schema) *Schema {
+	s.Components.Schemas[name] = schema
+	return Ref(name)
+}
+
+// AddSecurityScheme registers a security scheme. Operations reference it by name.
+func (s *Spec) AddSecurityScheme(name string, scheme *SecurityScheme) {
+	if s.Components.SecuritySchemes == nil {
+		s.Components.SecuritySchemes = map[string]*SecurityScheme{}
+	}
+
+	s.Components.SecuritySchemes[name] = scheme
+}
+
+// SecurityRequirements returns a requirement for each registered security scheme, any of which
+// satisfies an operation.
+func (s *Spec) SecurityRequirements() []SecurityRequirement {
+	var names []string
+
+	for name := range s.Components.SecuritySchemes {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var reqs []SecurityRequirement
+
+	for _, name := range names {
+		reqs = append(reqs, SecurityRequirement{name: {}})
+	}
+
+	return reqs
+}
+
+func (s *Spec) addTag(name string) {
+	for _, t := range s.Tags {
+		if t.Name == name {
+			return
+		}
+	}
+
+	s.Tags = append(s.Tags, Tag{Name: name})
+}
+
+// JSON returns a response with a JSON body.
+func JSON(description string, schema *Schema) *Response {
+	return &Response{
+		Description: description,
+		Content:     map[string]MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+// ResponseRef returns a reference to a response component.
+func ResponseRef(name string) *Response {
+	return &Response{Ref: "#/components/responses/" + name}
+}
+
+func templatePath(path string) string {
+	parts := strings.Split(path, "/")
+
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+
+	return strings.Join(parts, "/")
+}
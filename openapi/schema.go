@@ -0,0 +1,223 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Schema is a JSON Schema (draft 2020-12) as used by OpenAPI 3.1.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 any                `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	// BSONName is the name a property is stored as in Mongo when it differs from its JSON name.
+	BSONName string `json:"x-bson-name,omitempty"`
+}
+
+// Ref returns a reference to a schema component.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	dateTimeType   = reflect.TypeOf(primitive.DateTime(0))
+	objectIDType   = reflect.TypeOf(primitive.ObjectID{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textType       = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// ObjectID describes a hex encoded Mongo ObjectID.
+func ObjectID() *Schema {
+	return &Schema{Type: "string", Pattern: "^[0-9a-f]{24}$"}
+}
+
+// DateTime describes an RFC 3339 timestamp.
+func DateTime() *Schema {
+	return &Schema{Type: "string", Format: "date-time"}
+}
+
+// Schema describes the JSON encoding of t. Named struct types are registered as components and
+// referenced, so recursive types are supported.
+func (s *Spec) Schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType, dateTimeType:
+		return DateTime()
+	case objectIDType:
+		return ObjectID()
+	case rawMessageType:
+		return &Schema{}
+	}
+
+	// Types with their own encoding can't be described reliably
+	if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		return &Schema{}
+	}
+
+	if t.Implements(textType) || reflect.PointerTo(t).Implements(textType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+
+		return &Schema{Type: "array", Items: s.Schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.Schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+
+		if name, ok := s.names[t]; ok {
+			return Ref(name)
+		}
+
+		name := s.name(t)
+		s.names[t] = name
+		s.Components.Schemas[name] = &Schema{}
+		*s.Components.Schemas[name] = *s.object(t)
+
+		return Ref(name)
+	default:
+		return &Schema{}
+	}
+}
+
+// Name returns the component name of a struct type described by Schema.
+func (s *Spec) Name(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	s.Schema(t)
+
+	return s.names[t]
+}
+
+var invalidName = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// name derives a unique component name from a type name, stripping the package paths of type
+// arguments.
+func (s *Spec) name(t reflect.Type) string {
+	name := t.Name()
+
+	if i := strings.Index(name, "["); i >= 0 {
+		args := strings.Split(strings.TrimSuffix(name[i+1:], "]"), ",")
+
+		for j, arg := range args {
+			args[j] = arg[strings.LastIndex(arg, ".")+1:]
+		}
+
+		name = name[:i] + "_" + strings.Join(args, "_")
+	}
+
+	name = invalidName.ReplaceAllString(name, "_")
+
+	unique := name
+
+	for i := 2; s.Components.Schemas[unique] != nil; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+
+	return unique
+}
+
+func (s *Spec) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	s.fields(t, schema)
+	return schema
+}
+
+// fields adds the properties of t to schema following encoding/json rules: embedded structs
+// without a JSON name are flattened and fields tagged "-" are omitted. Fields with a
+// `binding:"required"` tag are required, as gin enforces when binding requests.
+func (s *Spec) fields(t reflect.Type, schema *Schema) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				s.fields(ft, schema)
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := s.Schema(f.Type)
+
+		bsonName := strings.Split(f.Tag.Get("bson"), ",")[0]
+
+		if bsonName == "" {
+			bsonName = strings.ToLower(f.Name)
+		}
+
+		if bsonName != "-" && bsonName != name {
+			if prop.Ref != "" {
+				// Siblings of $ref are allowed in OpenAPI 3.1
+				prop = &Schema{Ref: prop.Ref}
+			}
+
+			prop.BSONName = bsonName
+		}
+
+		schema.Properties[name] = prop
+
+		if strings.Contains(f.Tag.Get("binding"), "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
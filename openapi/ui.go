@@ -0,0 +1,76 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UI is an interactive documentation page rendering a document.
+type UI string
+
+const (
+	SwaggerUI UI = "swagger"
+	Redoc     UI = "redoc"
+)
+
+// uiPolicy allows the documentation pages to load their assets from their CDNs, replacing the
+// stricter policy set for JSON responses.
+const uiPolicy = "default-src 'none'; script-src 'unsafe-inline' https://unpkg.com https://cdn.redoc.ly; " +
+	"style-src 'unsafe-inline' https://unpkg.com https://fonts.googleapis.com; font-src https://fonts.gstatic.com; " +
+	"img-src data: https:; connect-src 'self'; worker-src blob:; frame-ancestors 'none'"
+
+var uiTemplates = map[UI]*template.Template{
+	SwaggerUI: template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({url: {{.URL}}, dom_id: "#swagger-ui"});
+</script>
+</body>
+</html>
+`)),
+	Redoc: template.Must(template.New("redoc").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+</head>
+<body>
+<redoc spec-url="{{.URL}}"></redoc>
+<script src="https://cdn.redoc.ly/redoc/latest/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`)),
+}
+
+// Handler serves the ui page for the document at specURL. The page is rendered once, so
+// rendering errors are returned here rather than while serving.
+func (ui UI) Handler(title string, specURL string) (gin.HandlerFunc, error) {
+	tmpl, ok := uiTemplates[ui]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown OpenAPI UI %q", ui)
+	}
+
+	var page bytes.Buffer
+
+	if err := tmpl.Execute(&page, map[string]string{"Title": title, "URL": specURL}); err != nil {
+		return nil, fmt.Errorf("rendering OpenAPI UI: %w", err)
+	}
+
+	return func(c *gin.Context) {
+		c.Header("Content-Security-Policy", uiPolicy)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+	}, nil
+}
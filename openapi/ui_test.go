@@ -0,0 +1,41 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUIHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	if _, err := UI("unknown").Handler("API", "/openapi.json"); err == nil {
+		t.Fatal("unknown UI was accepted")
+	}
+
+	for _, ui := range []UI{SwaggerUI, Redoc} {
+		t.Run(string(ui), func(t *testing.T) {
+			h, err := ui.Handler("<API>", "/api/openapi.json")
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := gin.New()
+			r.GET("/docs", h)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/docs", nil))
+
+			body := w.Body.String()
+
+			if w.Code != http.StatusOK || w.Header().Get("Content-Security-Policy") == "" ||
+				!strings.Contains(body, "/api/openapi.json") || strings.Contains(body, "<API>") {
+				t.Fatalf("unexpected page %d %s", w.Code, body)
+			}
+		})
+	}
+}
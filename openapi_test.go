@@ -0,0 +1,39 @@
+package scaffold
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIFilters(t *testing.T) {
+	profiles := NewCollection(CollectionOpts[profile]{Name: "Profiles", Slug: "profiles", Filterable: []string{"name"}})
+	spec := New(ScaffoldOpts{Collections: []Collection{profiles}}).OpenAPI()
+
+	params := func(path string, method string) []string {
+		var names []string
+
+		for _, p := range spec.Paths[path][method].Parameters {
+			names = append(names, p.Name)
+		}
+
+		return names
+	}
+
+	if names := strings.Join(params("/profiles/", "get"), ","); names != "limit,page,sort,created,id,last_updated,name" {
+		t.Fatalf("listing takes %s", names)
+	}
+
+	if names := strings.Join(params("/profiles/_changes", "get"), ","); names != "Last-Event-ID,created,id,last_updated,name" {
+		t.Fatalf("change feed takes %s", names)
+	}
+
+	for _, p := range spec.Paths["/profiles/"]["get"].Parameters {
+		if p.Name == "sort" && !strings.HasSuffix(p.Description, "created, id, last_updated, name") {
+			t.Fatalf("sort is described as %q", p.Description)
+		}
+
+		if p.Name == "name" && p.Schema.Type != "string" {
+			t.Fatalf("name is described as %+v", p.Schema)
+		}
+	}
+}
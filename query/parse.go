@@ -0,0 +1,213 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Field is a document field which may be filtered and sorted on.
+type Field struct {
+	// Name is the name the field is stored as.
+	Name string
+	Type reflect.Type
+}
+
+// Fields are the fields clients may filter and sort on, keyed by the name used in requests.
+type Fields map[string]Field
+
+// Operators which may be used in client supplied filters, by the name used in requests.
+var Operators = map[string]ComparisonOperator{
+	"eq":  Equal,
+	"ne":  NotEqual,
+	"gt":  GreaterThan,
+	"gte": GreaterThanOrEqual,
+	"lt":  LessThan,
+	"lte": LessThanOrEqual,
+	"in":  In,
+	"nin": NotIn,
+}
+
+// Reserved query parameters which are not parsed as filters.
+var Reserved = map[string]bool{"limit": true, "page": true, "sort": true}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	dateTimeType = reflect.TypeOf(primitive.DateTime(0))
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// Parse builds a query from URL query parameters naming one of the fields. "field=value" matches
+// equal values and "field[op]=value" applies one of Operators, where "in" and "nin" take comma
+// separated values.
+// The "sort" parameter is a comma separated list of fields, descending when prefixed with "-".
+func (f Fields) Parse(values url.Values) (Query, error) {
+	var queries []Query
+
+	for key, vals := range values {
+		if Reserved[key] {
+			continue
+		}
+
+		name, op := key, "eq"
+
+		if i := strings.Index(key, "["); i > 0 && strings.HasSuffix(key, "]") {
+			name, op = key[:i], key[i+1:len(key)-1]
+		}
+
+		// Other parameters, such as cache busters or fields which are not filterable, are ignored
+		if _, ok := f[name]; !ok {
+			continue
+		}
+
+		for _, v := range vals {
+			q, err := f.Condition(name, op, v)
+
+			if err != nil {
+				return nil, err
+			}
+
+			queries = append(queries, q)
+		}
+	}
+
+	var q Query = &Logical{Operator: And, Queries: queries}
+
+	if len(queries) == 0 {
+		q = Empty()
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		return f.Sort(q, strings.Split(sort, ","))
+	}
+
+	return q, nil
+}
+
+// Condition compares the named field to value using one of Operators. The value is converted to
+// the type of the field.
+func (f Fields) Condition(name string, op string, value string) (Query, error) {
+	field, ok := f[name]
+
+	if !ok {
+		return nil, fmt.Errorf("cannot filter on %q", name)
+	}
+
+	operator, ok := Operators[op]
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported operator %q", op)
+	}
+
+	if operator == In || operator == NotIn {
+		var vals []any
+
+		for _, v := range strings.Split(value, ",") {
+			val, err := convert(v, field.Type)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s: %w", name, err)
+			}
+
+			vals = append(vals, val)
+		}
+
+		return &Comparison{Operator: operator, Field: field.Name, Value: vals}, nil
+	}
+
+	val, err := convert(value, field.Type)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for %s: %w", name, err)
+	}
+
+	return &Comparison{Operator: operator, Field: field.Name, Value: val}, nil
+}
+
+// Sort orders the results of q by fields, descending when prefixed with "-".
+func (f Fields) Sort(q Query, fields []string) (Query, error) {
+	var sort bson.D
+
+	for _, name := range fields {
+		dir := 1
+
+		if strings.HasPrefix(name, "-") {
+			name, dir = name[1:], -1
+		}
+
+		field, ok := f[name]
+
+		if !ok {
+			return nil, fmt.Errorf("cannot sort on %q", name)
+		}
+
+		sort = append(sort, bson.E{Key: field.Name, Value: dir})
+	}
+
+	return &sorted{Query: q, sort: sort}, nil
+}
+
+// Sorter is implemented by queries which order their results.
+type Sorter interface {
+	Sorting() bson.D
+}
+
+type sorted struct {
+	Query
+	sort bson.D
+}
+
+func (q *sorted) Sorting() bson.D {
+	return q.sort
+}
+
+func convert(v string, t reflect.Type) (any, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case objectIDType:
+		return primitive.ObjectIDFromHex(v)
+	case timeType:
+		return time.Parse(time.RFC3339, v)
+	case dateTimeType:
+		tm, err := time.Parse(time.RFC3339, v)
+		return primitive.NewDateTimeFromTime(tm), err
+	}
+
+	var val any
+	var err error
+
+	switch t.Kind() {
+	case reflect.String:
+		val = v
+	case reflect.Bool:
+		val, err = strconv.ParseBool(v)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		val, err = strconv.ParseInt(v, 10, t.Bits())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		val, err = strconv.ParseUint(v, 10, t.Bits())
+	case reflect.Float32, reflect.Float64:
+		val, err = strconv.ParseFloat(v, t.Bits())
+	case reflect.Slice, reflect.Array:
+		// Matches documents where any element equals the value
+		return convert(v, t.Elem())
+	default:
+		return nil, errors.New("field type does not support filtering")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep named types (e.g. string enums) encoding as their underlying type
+	return reflect.ValueOf(val).Convert(t).Interface(), nil
+}
@@ -0,0 +1,58 @@
+package query
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	fields := Fields{
+		"name":  {Name: "name", Type: reflect.TypeOf("")},
+		"count": {Name: "count", Type: reflect.TypeOf(0)},
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		filter string
+		err    bool
+	}{
+		{"no filters", "limit=10&page=2", "map[]", false},
+		{"equal", "name=ada", "map[$and:[map[name:map[$eq:ada]]]]", false},
+		{"operator", "count[gte]=3", "map[$and:[map[count:map[$gte:3]]]]", false},
+		{"unknown parameters are ignored", "secret=x&secret[gt]=a&_=123", "map[]", false},
+		{"unsupported operator", "name[regex]=a", "", true},
+		{"invalid value", "count=many", "", true},
+		{"sort on unknown field", "sort=secret", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			q, err := fields.Parse(values)
+
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := fmt.Sprint(q.Filter()); got != tt.filter {
+				t.Fatalf("filter = %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	// full is how long the bucket takes to refill completely
+	full time.Duration
+}
+
+// MemoryStore keeps buckets in process memory. Limits are not shared between instances.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*bucket{}}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	burst := float64(limit.burst())
+
+	b, ok := s.buckets[key]
+
+	if !ok {
+		b = &bucket{tokens: burst, updated: now, full: time.Duration(burst / limit.rate() * float64(time.Second))}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.updated).Seconds()*limit.rate())
+	b.updated = now
+
+	allowed := b.tokens >= 1
+
+	if allowed {
+		b.tokens--
+	}
+
+	s.takes++
+
+	if s.takes%1024 == 0 {
+		s.sweep(now)
+	}
+
+	return result(limit, b.tokens, allowed), nil
+}
+
+// sweep drops buckets which have been idle long enough to refill completely.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.updated) > b.full {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoStore keeps buckets in a Mongo collection so limits are shared between instances. Each
+// take is a single atomic pipeline update.
+type MongoStore struct {
+	mc *mongo.Collection
+}
+
+func NewMongoStore(ctx context.Context, mc *mongo.Collection) (*MongoStore, error) {
+	_, err := mc.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoStore{mc: mc}, nil
+}
+
+func (s *MongoStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+	burst := float64(limit.burst())
+	full := time.Duration(burst / limit.rate() * float64(time.Second))
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$min": bson.A{burst, bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$tokens", burst}},
+				bson.M{"$multiply": bson.A{
+					bson.M{"$divide": bson.A{
+						bson.M{"$subtract": bson.A{primitive.NewDateTimeFromTime(now), bson.M{"$ifNull": bson.A{"$updated", primitive.NewDateTimeFromTime(now)}}}},
+						1000,
+					}},
+					limit.rate(),
+				}},
+			}}}},
+			"updated": primitive.NewDateTimeFromTime(now),
+		}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"tokens":  bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"expires": primitive.NewDateTimeFromTime(now.Add(full)),
+		}}},
+	}
+
+	var doc struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	err := s.mc.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&doc)
+
+	// Concurrent upserts of a new bucket race to insert it, the loser updates the winner's
+	if mongo.IsDuplicateKeyError(err) {
+		err = s.mc.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&doc)
+	}
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	return result(limit, doc.Tokens, doc.Allowed), nil
+}
@@ -0,0 +1,175 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/gin-gonic/gin"
+)
+
+// Limit is a token bucket allowing Requests per Per on average, with bursts of up to Burst
+// requests. Per defaults to one second and Burst defaults to Requests.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// Validate reports limits which cannot refill, such as those allowing no requests.
+func (l Limit) Validate() error {
+	if l.Requests <= 0 {
+		return errors.New("requests must be greater than 0")
+	}
+
+	if l.Per < 0 || l.Burst < 0 {
+		return errors.New("per and burst must not be negative")
+	}
+
+	return nil
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+
+	return l.Requests
+}
+
+// rate returns the number of tokens added to the bucket per second.
+func (l Limit) rate() float64 {
+	per := l.Per
+
+	if per <= 0 {
+		per = time.Second
+	}
+
+	return float64(l.Requests) / per.Seconds()
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until a token becomes available when the request was not allowed.
+	RetryAfter time.Duration
+}
+
+func result(l Limit, tokens float64, allowed bool) Result {
+	rate := l.rate()
+	burst := l.burst()
+
+	r := Result{
+		Allowed:   allowed,
+		Limit:     burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(burst) - tokens) / rate * float64(time.Second)),
+	}
+
+	if !allowed {
+		r.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+
+	return r
+}
+
+// Store holds token buckets.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// KeyFunc identifies the client making a request.
+type KeyFunc func(*gin.Context) string
+
+// ByIP keys requests by client IP.
+func ByIP() KeyFunc {
+	return func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// ByAPIKey keys requests made with an API key by the key, and other requests by client IP.
+func ByAPIKey() KeyFunc {
+	return func(c *gin.Context) string {
+		if p, ok := auth.FromContext(c); ok {
+			if id, ok := p.Claims["key_id"].(string); ok && p.Method == "api_key" {
+				return "key:" + id
+			}
+		}
+
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// ByPrincipal keys authenticated requests by principal, and anonymous requests by client IP.
+func ByPrincipal() KeyFunc {
+	return func(c *gin.Context) string {
+		if p, ok := auth.FromContext(c); ok && p.Subject != "" {
+			return "principal:" + p.Method + ":" + p.Subject
+		}
+
+		return "ip:" + c.ClientIP()
+	}
+}
+
+// Limiter applies limits to requests using a shared store.
+type Limiter struct {
+	store Store
+	key   KeyFunc
+}
+
+func New(store Store, key KeyFunc) *Limiter {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+
+	if key == nil {
+		key = ByPrincipal()
+	}
+
+	return &Limiter{store: store, key: key}
+}
+
+// Middleware limits requests within scope, e.g. a collection slug and operation. Responses
+// carry RateLimit-* headers, and requests over the limit are rejected with 429.
+func (l *Limiter) Middleware(scope string, limit Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		res, err := l.Take(c, scope, limit)
+
+		if err != nil {
+			http.Error(c, err)
+			c.Abort()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+			http.TooManyRequests(c, nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Take consumes a token from the bucket of the client making the request within scope.
+func (l *Limiter) Take(c *gin.Context, scope string, limit Limit) (Result, error) {
+	return l.store.Take(c, scope+"|"+l.key(c), limit)
+}
+
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
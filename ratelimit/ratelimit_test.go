@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit Limit
+		ok    bool
+	}{
+		{"valid", Limit{Requests: 10, Per: time.Minute, Burst: 2}, true},
+		{"defaults", Limit{Requests: 1}, true},
+		{"no requests", Limit{}, false},
+		{"negative requests", Limit{Requests: -1}, false},
+		{"negative per", Limit{Requests: 1, Per: -time.Second}, false},
+		{"negative burst", Limit{Requests: 1, Burst: -1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limit.Validate(); (err == nil) != tt.ok {
+				t.Fatalf("Validate() = %v", err)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMongoStore(t *testing.T) {
+	uri := os.Getenv("MONGO_URI")
+
+	if uri == "" {
+		t.Skip("MONGO_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := client.Database("ratelimit_test_" + primitive.NewObjectID().Hex())
+
+	t.Cleanup(func() {
+		db.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+
+	store, err := NewMongoStore(ctx, db.Collection("buckets"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Buckets are updated with aggregation pipelines, which some Mongo compatible servers lack
+	if _, err := store.Take(ctx, "probe", Limit{Requests: 1}); err != nil {
+		var cmd mongo.CommandError
+
+		if errors.As(err, &cmd) && cmd.Name == "NotImplemented" {
+			t.Skip(err)
+		}
+
+		t.Fatal(err)
+	}
+
+	testStore(t, store)
+
+	t.Run("concurrent new bucket", func(t *testing.T) {
+		limit := Limit{Requests: 100, Per: time.Hour}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 20)
+
+		for i := 0; i < cap(errs); i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				if _, err := store.Take(ctx, "concurrent", limit); err != nil {
+					errs <- err
+				}
+			}()
+		}
+
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Fatal(err)
+		}
+	})
+}
+
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+	limit := Limit{Requests: 1, Per: time.Hour, Burst: 2}
+
+	for i, allowed := range []bool{true, true, false} {
+		res, err := store.Take(ctx, "client", limit)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if res.Allowed != allowed {
+			t.Fatalf("take %d: allowed = %v", i, res.Allowed)
+		}
+
+		if !allowed && res.RetryAfter <= 0 {
+			t.Fatalf("take %d: no retry after", i)
+		}
+	}
+
+	// Buckets are independent
+	if res, err := store.Take(ctx, "other", limit); err != nil || !res.Allowed {
+		t.Fatalf("other client: %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,110 @@
+package scaffold
+
+import (
+	"context"
+	nethttp "net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/alexsobiek/scaffold/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	notes := NewCollection(CollectionOpts[note]{Name: "Notes", Slug: "notes"})
+	_, h := testScaffold(t, ScaffoldOpts{
+		Collections: []Collection{notes},
+		APIKeys:     &APIKeyOpts{},
+		RateLimit:   &RateLimitOpts{Limit: &ratelimit.Limit{Requests: 2, Per: time.Hour}},
+	})
+
+	// Requests with invalid credentials are limited before they are rejected
+	expectStatus(t, request(h, "GET", "/notes/", nil, "X-API-Key", "sk_invalid"), nethttp.StatusUnauthorized)
+	expectStatus(t, request(h, "GET", "/notes/", nil, "X-API-Key", "sk_invalid"), nethttp.StatusUnauthorized)
+
+	w := request(h, "GET", "/notes/", nil, "X-API-Key", "sk_invalid")
+	expectStatus(t, w, nethttp.StatusTooManyRequests)
+
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatal("limited response has no Retry-After")
+	}
+}
+
+func TestRateLimitForwardedFor(t *testing.T) {
+	notes := NewCollection(CollectionOpts[note]{Name: "Notes", Slug: "notes"})
+	limit := &RateLimitOpts{Limit: &ratelimit.Limit{Requests: 1, Per: time.Hour}}
+
+	limited := func(h nethttp.Handler, header string, ip string) bool {
+		return request(h, "GET", "/notes/", nil, header, ip).Code == nethttp.StatusTooManyRequests
+	}
+
+	t.Run("untrusted", func(t *testing.T) {
+		_, h := testScaffold(t, ScaffoldOpts{Collections: []Collection{notes}, RateLimit: limit})
+
+		// Forwarding headers from clients are ignored, so they cannot pick a fresh bucket
+		if limited(h, "X-Forwarded-For", "198.51.100.1") {
+			t.Fatal("first request was limited")
+		}
+
+		if !limited(h, "X-Forwarded-For", "198.51.100.2") || !limited(h, "X-Real-IP", "198.51.100.3") {
+			t.Fatal("spoofed forwarding header bypassed the limit")
+		}
+	})
+
+	t.Run("trusted proxy", func(t *testing.T) {
+		// httptest requests come from 192.0.2.1
+		_, h := testScaffold(t, ScaffoldOpts{
+			Collections:    []Collection{notes},
+			RateLimit:      limit,
+			TrustedProxies: []string{"192.0.2.1"},
+		})
+
+		if limited(h, "X-Forwarded-For", "198.51.100.1") || limited(h, "X-Forwarded-For", "198.51.100.2") {
+			t.Fatal("clients behind the proxy share a limit")
+		}
+
+		if !limited(h, "X-Forwarded-For", "198.51.100.1") {
+			t.Fatal("forwarded client was not limited")
+		}
+	})
+}
+
+func TestRateLimitValidation(t *testing.T) {
+	uri := os.Getenv("MONGO_URI")
+
+	if uri == "" {
+		t.Skip("MONGO_URI is not set")
+	}
+
+	tests := []struct {
+		name string
+		opts ScaffoldOpts
+	}{
+		{"global", ScaffoldOpts{RateLimit: &RateLimitOpts{Limit: &ratelimit.Limit{}}}},
+		{"collection", ScaffoldOpts{Collections: []Collection{NewCollection(CollectionOpts[note]{
+			Name:       "Notes",
+			Slug:       "notes",
+			RateLimits: map[auth.Operation]ratelimit.Limit{auth.OpCreate: {Requests: 0}},
+		})}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.MongoURI = uri
+			tt.opts.Database = "scaffold_test_ratelimit"
+			s := New(tt.opts)
+
+			_, err := s.Handler(context.Background())
+
+			if err == nil {
+				t.Fatal("expected invalid rate limit to be rejected")
+			} else if !strings.Contains(err.Error(), "rate limit") {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			s.Shutdown(context.Background())
+		})
+	}
+}
@@ -2,7 +2,12 @@ package scaffold
 
 import (
 	"reflect"
+	"slices"
 	"strings"
+
+	"github.com/alexsobiek/scaffold/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type bsonField struct {
@@ -24,3 +29,81 @@ func getFieldNames(f reflect.StructField) bsonField {
 		BsonField:  bsonTag,
 	}
 }
+
+// queryFields returns the fields of documents holding t which clients may filter and sort on,
+// keyed by their JSON name: the document metadata and the fields of t named in filterable.
+func queryFields(t reflect.Type, filterable []string) query.Fields {
+	fields := query.Fields{
+		"id":           {Name: "_id", Type: reflect.TypeOf(primitive.ObjectID{})},
+		"created":      {Name: "created", Type: reflect.TypeOf(primitive.DateTime(0))},
+		"last_updated": {Name: "last_updated", Type: reflect.TypeOf(primitive.DateTime(0))},
+	}
+
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name, bsonName := jsonName(f), bsonName(f)
+
+		if name == "-" || bsonName == "-" || !slices.Contains(filterable, name) {
+			continue
+		}
+
+		fields[name] = query.Field{Name: bsonName, Type: f.Type}
+	}
+
+	return fields
+}
+
+// jsonName returns the name of f in JSON, or "-" when it is omitted.
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+
+	if name == "" {
+		name = f.Name
+	}
+
+	return name
+}
+
+// bsonName returns the name f is stored as, or "-" when it is omitted.
+func bsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("bson"), ",")[0]
+
+	if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+
+	return name
+}
+
+// inline reports whether f is stored inline, its fields alongside those of the struct holding it.
+func inline(f reflect.StructField) bool {
+	return slices.Contains(strings.Split(f.Tag.Get("bson"), ",")[1:], "inline")
+}
+
+// setInline adds the fields of v, an embedded struct stored inline, to updates under the names
+// they are stored as.
+func setInline(updates bson.M, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+
+		if !f.IsExported() || bsonName(f) == "-" {
+			continue
+		}
+
+		if f.Anonymous && inline(f) {
+			setInline(updates, v.Field(i))
+			continue
+		}
+
+		updates[bsonName(f)] = v.Field(i).Interface()
+	}
+}
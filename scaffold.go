@@ -2,26 +2,149 @@ package scaffold
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	nethttp "net/http"
 	"os"
+	"sync"
+	"time"
 
+	"github.com/alexsobiek/scaffold/auth"
 	"github.com/alexsobiek/scaffold/http"
+	"github.com/alexsobiek/scaffold/metrics"
+	"github.com/alexsobiek/scaffold/ratelimit"
+	"github.com/alexsobiek/scaffold/tenant"
+	"github.com/alexsobiek/scaffold/tracing"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var Context = context.Background()
 
+// LifecycleHook is run when Scaffold starts or stops.
+type LifecycleHook func(context.Context) error
+
 type ScaffoldOpts struct {
 	Collections []Collection
 	MongoURI    string
 	Database    string
 	Address     string
-	Logger      *log.Logger
+	// Listeners replace Address to serve on several addresses, Unix sockets, with TLS or h2c.
+	Listeners []http.Listener
+	Logger    *log.Logger
+	// LogHandler receives structured logs, including the access log. When nil, logs are written
+	// as text to Logger's output.
+	LogHandler slog.Handler
+	// CORS enables cross-origin requests from browsers.
+	CORS *http.CORSOpts
+	// SecurityHeaders adds HSTS, nosniff, frame options and CSP headers to every response.
+	SecurityHeaders *http.SecurityHeadersOpts
+	// TrustedProxies lists the proxies whose X-Forwarded-For and X-Real-IP headers identify the
+	// client, e.g. for rate limiting. No proxy is trusted by default. It does not apply to Mount,
+	// which uses the settings of the engine mounted on.
+	TrustedProxies []string
+	// ShutdownTimeout bounds how long Run waits for in-flight requests when shutting down,
+	// defaults to 10 seconds.
+	ShutdownTimeout time.Duration
+	// OnStart hooks run once routes are registered, before serving.
+	OnStart []LifecycleHook
+	// OnStop hooks run once the server has stopped, before Mongo is disconnected.
+	OnStop []LifecycleHook
+	// JWT enables bearer token authentication when set.
+	JWT *auth.JWTOpts
+	// APIKeys enables API key authentication backed by a Scaffold-managed collection.
+	APIKeys *APIKeyOpts
+	// Accounts enables the built-in username/password users module.
+	Accounts *AccountOpts
+	// OIDC enables login through an OpenID Connect issuer.
+	OIDC *OIDCOpts
+	// Sessions configures cookie sessions used by Accounts and OIDC.
+	Sessions *SessionOpts
+	// Authenticators are additional authenticators tried after the built-in ones.
+	Authenticators []auth.Authenticator
+	// Metrics enables the Prometheus metrics endpoint.
+	Metrics *MetricsOpts
+	// Tracing enables OpenTelemetry spans for requests, hooks and Mongo operations.
+	Tracing *TracingOpts
+	// HealthChecks are run by /readyz in addition to the built-in checks.
+	HealthChecks map[string]HealthCheck
+	// RateLimit configures per-client rate limiting.
+	RateLimit *RateLimitOpts
+	// Tenancy scopes documents to the tenant of each request.
+	Tenancy *TenancyOpts
+	// OpenAPI serves a generated OpenAPI 3.1 document describing the collections.
+	OpenAPI *OpenAPIOpts
+	// Migrations registers data migrations, applied on Start unless Manual is set.
+	Migrations *MigrationOpts
+	// GraphQL serves a GraphQL endpoint generated from the collections.
+	GraphQL *GraphQLOpts
+	// RequireAuth rejects anonymous requests to collection routes with 401.
+	RequireAuth bool
+}
+
+type MetricsOpts struct {
+	// Path is where metrics are served in the Prometheus text format, defaults to "/metrics".
+	Path string
+	// Registry metrics are registered with, defaults to a new registry including Go runtime and
+	// process collectors.
+	Registry *prometheus.Registry
+	// Role is required to scrape metrics, defaults to "admin". Scrapers can authenticate with an
+	// API key or JWT carrying it.
+	Role string
+	// Public serves metrics without authentication, e.g. when Path is only reachable from the
+	// scraper's network.
+	Public bool
+}
+
+type TracingOpts struct {
+	// TracerProvider creates the tracer used for spans. When nil, a provider exporting to
+	// Exporter is created.
+	TracerProvider trace.TracerProvider
+	// Exporter receives batches of spans when TracerProvider is nil, e.g. an OTLP exporter.
+	// Defaults to tracing.StdoutExporter.
+	Exporter    sdktrace.SpanExporter
+	ServiceName string
+}
+
+type RateLimitOpts struct {
+	// Limit is applied to every request by client IP when set. It is checked before
+	// authentication so attempts with invalid credentials are limited too. Collections can declare
+	// further limits with CollectionOpts.RateLimits.
+	Limit *ratelimit.Limit
+	// Key identifies clients for collection limits, defaults to ratelimit.ByPrincipal.
+	Key ratelimit.KeyFunc
+	// Store holds the token buckets, defaults to an in-memory store.
+	Store ratelimit.Store
+	// Collection stores token buckets in Mongo when Store is not set, sharing limits between
+	// instances.
+	Collection string
 }
 
 type Scaffold struct {
-	opts ScaffoldOpts
-	db   *Database
-	http *http.HttpServer
+	opts       ScaffoldOpts
+	log        *slog.Logger
+	db         *Database
+	http       *http.HttpServer
+	router     gin.IRouter
+	serving    bool
+	keys       *APIKeys
+	sessions   *sessions
+	accounts   *Accounts
+	oidc       *OIDC
+	limiter    *ratelimit.Limiter
+	metrics    *metrics.Metrics
+	tracer     trace.Tracer
+	provider   *sdktrace.TracerProvider
+	health     *health
+	migrations *Migrations
+	// stop is closed by Shutdown to end long-lived responses such as change feeds.
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 func New(opts ScaffoldOpts) *Scaffold {
@@ -33,29 +156,505 @@ func New(opts ScaffoldOpts) *Scaffold {
 		opts.Address = ":3000"
 	}
 
+	if opts.ShutdownTimeout == 0 {
+		opts.ShutdownTimeout = 10 * time.Second
+	}
+
+	if opts.Tracing != nil && opts.Tracing.ServiceName == "" {
+		opts.Tracing.ServiceName = "scaffold"
+	}
+
+	if opts.Metrics != nil && opts.Metrics.Path == "" {
+		opts.Metrics.Path = "/metrics"
+	}
+
+	if opts.Metrics != nil && opts.Metrics.Role == "" {
+		opts.Metrics.Role = "admin"
+	}
+
+	if opts.Tenancy != nil && opts.Tenancy.Resolver == nil {
+		opts.Tenancy.Resolver = tenant.Header("X-Tenant-ID")
+	}
+
+	if opts.OpenAPI != nil {
+		if opts.OpenAPI.Path == "" {
+			opts.OpenAPI.Path = "/openapi.json"
+		}
+
+		if opts.OpenAPI.UIPath == "" {
+			opts.OpenAPI.UIPath = "/docs"
+		}
+
+		if opts.OpenAPI.Title == "" {
+			opts.OpenAPI.Title = "Scaffold"
+		}
+
+		if opts.OpenAPI.Version == "" {
+			opts.OpenAPI.Version = "1.0.0"
+		}
+	}
+
+	if opts.GraphQL != nil {
+		if opts.GraphQL.Path == "" {
+			opts.GraphQL.Path = "/graphql"
+		}
+
+		if opts.GraphQL.MaxDepth == 0 {
+			opts.GraphQL.MaxDepth = 10
+		}
+	}
+
+	if opts.LogHandler == nil {
+		opts.LogHandler = slog.NewTextHandler(opts.Logger.Writer(), nil)
+	}
+
 	s := &Scaffold{
-		opts: opts,
+		opts:   opts,
+		log:    slog.New(opts.LogHandler),
+		health: newHealth(opts.HealthChecks),
+		stop:   make(chan struct{}),
 	}
 
 	return s
 }
 
+// Run starts Scaffold and blocks until ctx is cancelled or the server fails, then shuts down,
+// waiting up to ShutdownTimeout for in-flight requests to complete.
 func (s *Scaffold) Run(ctx context.Context) error {
-	db, err := NewDatabase(ctx, s.opts.MongoURI, s.opts.Database)
+	if err := s.Start(ctx); err != nil {
+		return err
+	}
+
+	var err error
+
+	select {
+	case <-ctx.Done():
+	case err = <-s.http.Errors():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.opts.ShutdownTimeout)
+	defer cancel()
+
+	return errors.Join(err, s.Shutdown(shutdownCtx))
+}
+
+// Start connects to Mongo, registers all routes, runs the OnStart hooks and starts serving in the
+// background.
+func (s *Scaffold) Start(ctx context.Context) error {
+	var err error
+
+	if s.http, err = http.Create(s.httpOpts()); err != nil {
+		return err
+	}
+
+	if err := s.prepare(ctx, s.http.Router()); err != nil {
+		return err
+	}
+
+	if err := s.http.Start(); err != nil {
+		return errors.Join(err, s.db.Close(context.WithoutCancel(ctx)))
+	}
+
+	s.serving = true
+
+	return nil
+}
+
+// Handler connects to Mongo and runs the OnStart hooks like Start, but returns the handler
+// serving all routes instead of listening, e.g. for use with httptest. Call Shutdown to
+// disconnect from Mongo once done.
+func (s *Scaffold) Handler(ctx context.Context) (nethttp.Handler, error) {
+	var err error
+
+	if s.http, err = http.Create(s.httpOpts()); err != nil {
+		return nil, err
+	}
+
+	if err := s.prepare(ctx, s.http.Router()); err != nil {
+		return nil, err
+	}
+
+	return s.http.Router(), nil
+}
+
+// Mount connects to Mongo and runs the OnStart hooks like Start, but registers all routes on an
+// existing router group, under its path prefix, instead of listening. The engine the group
+// belongs to should have ContextWithFallback enabled so request values such as the trace span
+// reach collection hooks. Call Shutdown to disconnect from Mongo once done.
+func (s *Scaffold) Mount(ctx context.Context, rg *gin.RouterGroup) error {
+	root := rg.Group("")
+	root.Use(http.Middleware(s.httpOpts())...)
+
+	return s.prepare(ctx, root)
+}
+
+// prepare sets up Scaffold on rg and runs the OnStart hooks, disconnecting from Mongo on failure.
+func (s *Scaffold) prepare(ctx context.Context, rg gin.IRouter) error {
+	err := s.setup(ctx, rg)
+
+	for i := 0; err == nil && i < len(s.opts.OnStart); i++ {
+		err = s.opts.OnStart[i](ctx)
+	}
+
+	if err != nil && s.db != nil {
+		err = errors.Join(err, s.db.Close(context.WithoutCancel(ctx)))
+	}
+
+	return err
+}
+
+func (s *Scaffold) httpOpts() http.Opts {
+	return http.Opts{
+		Logger:          s.log,
+		Address:         s.opts.Address,
+		Listeners:       s.opts.Listeners,
+		CORS:            s.opts.CORS,
+		SecurityHeaders: s.opts.SecurityHeaders,
+		TrustedProxies:  s.opts.TrustedProxies,
+	}
+}
+
+// Shutdown stops serving, waiting for in-flight requests to complete until ctx is done, runs the
+// OnStop hooks, flushes traces and disconnects from Mongo.
+func (s *Scaffold) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	s.stopOnce.Do(func() { close(s.stop) })
+
+	if s.serving {
+		errs = append(errs, s.http.Shutdown(ctx))
+	}
+
+	for _, hook := range s.opts.OnStop {
+		errs = append(errs, hook(ctx))
+	}
+
+	if s.provider != nil {
+		errs = append(errs, s.provider.Shutdown(ctx))
+	}
+
+	if s.db != nil {
+		errs = append(errs, s.db.Close(ctx))
+	}
+
+	return errors.Join(errs...)
+}
+
+// OnStart registers a hook run by Start once routes are registered, before serving.
+func (s *Scaffold) OnStart(hook LifecycleHook) {
+	s.opts.OnStart = append(s.opts.OnStart, hook)
+}
+
+// OnStop registers a hook run by Shutdown once the server has stopped, before Mongo is
+// disconnected.
+func (s *Scaffold) OnStop(hook LifecycleHook) {
+	s.opts.OnStop = append(s.opts.OnStop, hook)
+}
+
+func (s *Scaffold) setup(ctx context.Context, rg gin.IRouter) error {
+	s.router = rg
+
+	if s.opts.CORS != nil {
+		if err := s.opts.CORS.Validate(); err != nil {
+			return err
+		}
+	}
+
+	clientOpts := options.Client()
+
+	if s.opts.Metrics != nil {
+		s.metrics = metrics.New(s.opts.Metrics.Registry)
+		clientOpts.SetPoolMonitor(s.metrics.PoolMonitor())
+	}
+
+	db, err := s.connect(ctx, clientOpts)
 
 	if err != nil {
 		return err
 	}
 
+	s.health.add("mongo", func(ctx context.Context) error {
+		return db.client.Ping(ctx, nil)
+	})
+
+	s.health.inject(s.router)
+
+	if s.migrations != nil && !s.migrations.opts.Manual {
+		if _, err := s.migrations.Up(ctx, false); err != nil {
+			return err
+		}
+	}
+
+	if s.opts.OpenAPI != nil {
+		if err := s.injectOpenAPI(); err != nil {
+			return err
+		}
+	}
+
+	if s.opts.APIKeys != nil {
+		s.keys, err = newAPIKeys(db, *s.opts.APIKeys)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.opts.Accounts != nil || s.opts.OIDC != nil || s.opts.Sessions != nil {
+		opts := SessionOpts{}
+
+		if s.opts.Sessions != nil {
+			opts = *s.opts.Sessions
+		}
+
+		s.sessions, err = newSessions(db, opts)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.opts.Accounts != nil {
+		s.accounts, err = newAccounts(db, *s.opts.Accounts, s.sessions)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.opts.OIDC != nil {
+		s.oidc, err = newOIDC(ctx, db, *s.opts.OIDC, s.sessions)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.opts.Tracing != nil {
+		tp := s.opts.Tracing.TracerProvider
+
+		if tp == nil {
+			exporter := s.opts.Tracing.Exporter
+
+			if exporter == nil {
+				exporter, err = tracing.StdoutExporter()
+
+				if err != nil {
+					return err
+				}
+			}
+
+			s.provider = tracing.NewProvider(s.opts.Tracing.ServiceName, exporter)
+			tp = s.provider
+		}
+
+		s.tracer = tp.Tracer(tracing.Name)
+		s.router.Use(tracing.Middleware(s.tracer))
+	}
+
+	if s.metrics != nil {
+		s.router.Use(s.metrics.Middleware())
+
+		if s.opts.Metrics.Public {
+			s.router.GET(s.opts.Metrics.Path, gin.WrapH(s.metrics.Handler()))
+		}
+	}
+
+	if err := s.setupRateLimit(ctx); err != nil {
+		return err
+	}
+
+	authenticators, err := s.authenticators()
+
+	if err != nil {
+		return err
+	}
+
+	s.router.Use(auth.Middleware(authenticators...))
+
+	if s.metrics != nil && !s.opts.Metrics.Public {
+		s.router.GET(s.opts.Metrics.Path, auth.RequireRole(s.opts.Metrics.Role), gin.WrapH(s.metrics.Handler()))
+	}
+
+	if s.keys != nil {
+		s.keys.inject(s.router.Group(s.keys.opts.AdminPath))
+	}
+
+	if s.migrations != nil {
+		s.migrations.inject(s.router.Group(s.migrations.opts.AdminPath))
+	}
+
+	if s.accounts != nil {
+		s.accounts.inject(s.router.Group(s.accounts.opts.Path))
+	}
+
+	if s.oidc != nil {
+		s.oidc.inject(s.router.Group(s.oidc.opts.Path))
+	}
+
+	for _, c := range s.opts.Collections {
+		if err := c.inject(ctx, s, s.dataGroup(c.Slug())); err != nil {
+			return err
+		}
+	}
+
+	if s.opts.GraphQL != nil {
+		if err := s.injectGraphQL(s.dataGroup(s.opts.GraphQL.Path)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Connect connects to Mongo without registering routes or serving, e.g. for command line tooling.
+// Close the returned database once done. When s is already connected, its connection is returned
+// and is closed by Shutdown instead.
+func (s *Scaffold) Connect(ctx context.Context) (*Database, error) {
+	if s.db != nil {
+		return s.db, nil
+	}
+
+	return s.connect(ctx, options.Client())
+}
+
+// Database returns the database s is connected to, or nil before Start or Connect.
+func (s *Scaffold) Database() *Database {
+	return s.db
+}
+
+func (s *Scaffold) connect(ctx context.Context, opts *options.ClientOptions) (*Database, error) {
+	db, err := NewDatabase(ctx, s.opts.MongoURI, s.opts.Database, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if s.opts.Migrations != nil {
+		s.migrations, err = newMigrations(db, *s.opts.Migrations, s.log)
+
+		if err != nil {
+			return nil, errors.Join(err, db.Close(ctx))
+		}
+	}
+
 	s.db = db
 
-	s.http = http.Create(s.opts.Logger, s.opts.Address)
+	return db, nil
+}
+
+// Transaction runs fn in a multi-document transaction, see Database.Transaction. It is available
+// once Start or Connect has connected to the database.
+func (s *Scaffold) Transaction(ctx context.Context, fn func(context.Context) error, opts ...*options.TransactionOptions) error {
+	if s.db == nil {
+		return errors.New("not connected to the database")
+	}
+
+	return s.db.Transaction(ctx, fn, opts...)
+}
+
+// Migrations returns the migration runner, or nil when no migrations are registered. It is
+// available once Start or Connect has connected to the database.
+func (s *Scaffold) Migrations() *Migrations {
+	return s.migrations
+}
+
+// Collections returns the registered collections.
+func (s *Scaffold) Collections() []Collection {
+	return s.opts.Collections
+}
+
+// APIKeys returns the API key manager, or nil when API keys are not enabled. It is available once
+// Start has connected to the database.
+func (s *Scaffold) APIKeys() *APIKeys {
+	return s.keys
+}
+
+// Accounts returns the users module, or nil when it is not enabled. It is available once Start has
+// connected to the database.
+func (s *Scaffold) Accounts() *Accounts {
+	return s.accounts
+}
+
+func (s *Scaffold) authenticators() ([]auth.Authenticator, error) {
+	var authenticators []auth.Authenticator
+
+	if s.opts.JWT != nil {
+		v, err := auth.NewJWTVerifier(*s.opts.JWT)
+
+		if err != nil {
+			return nil, err
+		}
+
+		authenticators = append(authenticators, v)
+	}
+
+	if s.keys != nil {
+		authenticators = append(authenticators, s.keys)
+	}
+
+	if s.sessions != nil {
+		authenticators = append(authenticators, s.sessions)
+	}
+
+	return append(authenticators, s.opts.Authenticators...), nil
+}
+
+// dataGroup creates a group for routes serving collection documents, requiring authentication
+// and resolving tenants when configured.
+func (s *Scaffold) dataGroup(path string) *gin.RouterGroup {
+	rg := s.router.Group(path)
+
+	if s.opts.RequireAuth {
+		rg.Use(auth.Require())
+	}
+
+	if s.opts.Tenancy != nil {
+		rg.Use(tenant.Middleware(s.opts.Tenancy.Resolver, s.opts.Tenancy.Optional))
+	}
+
+	return rg
+}
+
+func (s *Scaffold) setupRateLimit(ctx context.Context) error {
+	opts := RateLimitOpts{}
+
+	if s.opts.RateLimit != nil {
+		opts = *s.opts.RateLimit
+	}
+
+	if opts.Limit != nil {
+		if err := opts.Limit.Validate(); err != nil {
+			return fmt.Errorf("rate limit: %w", err)
+		}
+	}
 
 	for _, c := range s.opts.Collections {
-		c.inject(db.Collection(c.Slug()), s.http.Router().Group(c.Slug()))
+		for op, l := range c.limits() {
+			if err := l.Validate(); err != nil {
+				return fmt.Errorf("collection %s: %s rate limit: %w", c.Slug(), op, err)
+			}
+		}
+	}
+
+	if opts.Store == nil && opts.Collection != "" {
+		store, err := ratelimit.NewMongoStore(ctx, s.db.Collection(opts.Collection))
+
+		if err != nil {
+			return err
+		}
+
+		opts.Store = store
+	}
+
+	if opts.Store == nil {
+		opts.Store = ratelimit.NewMemoryStore()
 	}
 
-	s.http.Run()
+	s.limiter = ratelimit.New(opts.Store, opts.Key)
+
+	if opts.Limit != nil {
+		s.router.Use(ratelimit.New(opts.Store, ratelimit.ByIP()).Middleware("global", *opts.Limit))
+	}
 
 	return nil
 }
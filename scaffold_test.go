@@ -0,0 +1,105 @@
+package scaffold
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// testScaffold prepares s against the Mongo server in MONGO_URI, in a database of its own which
+// is dropped once the test ends. Tests using it are skipped when MONGO_URI is unset.
+func testScaffold(t *testing.T, opts ScaffoldOpts) (*Scaffold, nethttp.Handler) {
+	t.Helper()
+
+	uri := os.Getenv("MONGO_URI")
+
+	if uri == "" {
+		t.Skip("MONGO_URI is not set")
+	}
+
+	gin.SetMode(gin.TestMode)
+
+	opts.MongoURI = uri
+	opts.Database = "scaffold_test_" + primitive.NewObjectID().Hex()
+	opts.LogHandler = slog.NewTextHandler(io.Discard, nil)
+
+	s := New(opts)
+	h, err := s.Handler(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		ctx := context.Background()
+
+		if err := s.db.db.Drop(ctx); err != nil {
+			t.Error(err)
+		}
+
+		if err := s.Shutdown(ctx); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return s, h
+}
+
+// request serves a request with an optional JSON body, and headers given as name/value pairs.
+func request(h nethttp.Handler, method string, path string, body any, headers ...string) *httptest.ResponseRecorder {
+	var r io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+
+		if err != nil {
+			panic(err)
+		}
+
+		r = bytes.NewReader(b)
+	}
+
+	req := httptest.NewRequest(method, path, r)
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Add(headers[i], headers[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+// cookie returns the value of a cookie set by a response.
+func cookie(w *httptest.ResponseRecorder, name string) (*nethttp.Cookie, bool) {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c, true
+		}
+	}
+
+	return nil, false
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+
+	if w.Code != status {
+		t.Fatalf("expected %d, got %d: %s", status, w.Code, strings.TrimSpace(w.Body.String()))
+	}
+}
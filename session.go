@@ -0,0 +1,161 @@
+package scaffold
+
+import (
+	"context"
+	nethttp "net/http"
+	"time"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/alexsobiek/scaffold/query"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SessionOpts struct {
+	// Collection is the name of the collection sessions are stored in, defaults to "_sessions".
+	Collection string
+	// TTL is how long a session remains valid after login, defaults to 24 hours.
+	TTL          time.Duration
+	CookieName   string
+	CookieDomain string
+	CookiePath   string
+	CookieSecure bool
+}
+
+// Session is a cookie session stored in Mongo. Only a hash of the session token is persisted.
+type Session struct {
+	TokenHash string             `bson:"token_hash" json:"-"`
+	Principal auth.Principal     `bson:"principal" json:"principal"`
+	Expires   primitive.DateTime `bson:"expires" json:"expires"`
+}
+
+type sessions struct {
+	opts SessionOpts
+	c    *C[Session]
+}
+
+func newSessions(db *Database, opts SessionOpts) (*sessions, error) {
+	if opts.Collection == "" {
+		opts.Collection = "_sessions"
+	}
+
+	if opts.TTL == 0 {
+		opts.TTL = 24 * time.Hour
+	}
+
+	if opts.CookieName == "" {
+		opts.CookieName = "scaffold_session"
+	}
+
+	if opts.CookiePath == "" {
+		opts.CookiePath = "/"
+	}
+
+	c := NewCollection(CollectionOpts[Session]{
+		Name: "Sessions",
+		Slug: opts.Collection,
+	})
+
+	c.mc = db.Collection(opts.Collection)
+
+	_, err := c.mc.Indexes().CreateMany(db.ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "token_hash", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &sessions{opts: opts, c: c}, nil
+}
+
+// start creates a session for the principal and sets the session cookie on the response.
+func (s *sessions) start(c *gin.Context, p *auth.Principal) error {
+	token, err := randomToken()
+
+	if err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(s.opts.TTL)
+
+	_, err = s.c.Insert(c, Session{
+		TokenHash: hashToken(token),
+		Principal: *p,
+		Expires:   primitive.NewDateTimeFromTime(expires),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	s.setCookie(c, token, int(s.opts.TTL.Seconds()))
+
+	return nil
+}
+
+// end deletes the session of the request, if any, and clears the session cookie.
+func (s *sessions) end(c *gin.Context) error {
+	token, err := c.Cookie(s.opts.CookieName)
+
+	s.setCookie(c, "", -1)
+
+	if err != nil || token == "" {
+		return nil
+	}
+
+	doc, err := s.find(c, token)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return err
+	}
+
+	return doc.Delete(c)
+}
+
+func (s *sessions) find(ctx context.Context, token string) (*Document[Session], error) {
+	return s.c.Find(ctx, &query.Comparison{Operator: query.Equal, Field: "token_hash", Value: hashToken(token)})
+}
+
+func (s *sessions) setCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(nethttp.SameSiteLaxMode)
+	c.SetCookie(s.opts.CookieName, value, maxAge, s.opts.CookiePath, s.opts.CookieDomain, s.opts.CookieSecure, true)
+}
+
+// Authenticate resolves the principal of the session cookie. Unknown and expired sessions are
+// treated as anonymous so a stale cookie never prevents logging in again.
+func (s *sessions) Authenticate(c *gin.Context) (*auth.Principal, error) {
+	token, err := c.Cookie(s.opts.CookieName)
+
+	if err != nil || token == "" {
+		return nil, auth.ErrNoCredentials
+	}
+
+	doc, err := s.find(c, token)
+
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, auth.ErrNoCredentials
+		}
+		return nil, err
+	}
+
+	if doc.Data.Expires.Time().Before(time.Now()) {
+		return nil, auth.ErrNoCredentials
+	}
+
+	return &doc.Data.Principal, nil
+}
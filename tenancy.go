@@ -0,0 +1,52 @@
+package scaffold
+
+import (
+	"context"
+
+	"github.com/alexsobiek/scaffold/tenant"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type TenancyOpts struct {
+	// Resolver determines the tenant of each request, see tenant.Header, tenant.Subdomain and
+	// tenant.Claim.
+	Resolver tenant.Resolver
+	// Optional accepts requests which do not identify a tenant instead of rejecting them with 400.
+	// Like programmatic calls without tenant.With, they only see documents of no tenant.
+	Optional bool
+	// DatabasePerTenant stores each tenant's documents in a database of its own, named
+	// "<Database>_<tenant>", rather than stamping a tenant_id on documents in shared collections.
+	DatabasePerTenant bool
+}
+
+// collection returns the mongo collection holding the documents visible to ctx.
+func (c *C[T]) collection(ctx context.Context) *mongo.Collection {
+	if c.tenancy != nil && c.tenancy.DatabasePerTenant {
+		if id, ok := tenant.From(ctx); ok {
+			return c.db.TenantCollection(id, c.mc.Name())
+		}
+	}
+
+	return c.mc
+}
+
+// filter scopes a filter to the tenant of ctx when collections are shared between tenants.
+// Without a tenant only documents which belong to no tenant match, never those of every tenant.
+func (c *C[T]) filter(ctx context.Context, filter bson.M) bson.M {
+	if c.tenancy == nil || c.tenancy.DatabasePerTenant {
+		return filter
+	}
+
+	scope := bson.M{"tenant_id": bson.M{"$exists": false}}
+
+	if id, ok := tenant.From(ctx); ok {
+		scope = bson.M{"tenant_id": id}
+	}
+
+	if len(filter) == 0 {
+		return scope
+	}
+
+	return bson.M{"$and": []bson.M{filter, scope}}
+}
@@ -0,0 +1,135 @@
+package scaffold
+
+import (
+	"context"
+	"encoding/json"
+	nethttp "net/http"
+	"testing"
+
+	"github.com/alexsobiek/scaffold/tenant"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTenancy(t *testing.T) {
+	for _, optional := range []bool{false, true} {
+		name := "required"
+
+		if optional {
+			name = "optional"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			notes := NewCollection(CollectionOpts[note]{
+				Name:   "Notes",
+				Slug:   "notes",
+				Access: func(context.Context, primitive.ObjectID) error { return nil },
+			})
+
+			_, h := testScaffold(t, ScaffoldOpts{
+				Collections: []Collection{notes},
+				Tenancy:     &TenancyOpts{Optional: optional},
+			})
+
+			ctx := context.Background()
+
+			acme, err := notes.Insert(tenant.With(ctx, "acme"), note{Text: "acme"})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			globex, err := notes.Insert(tenant.With(ctx, "globex"), note{Text: "globex"})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			shared, err := notes.Insert(ctx, note{Text: "shared"})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			list := func(headers ...string) []string {
+				t.Helper()
+
+				w := request(h, "GET", "/notes/", nil, headers...)
+
+				if w.Code == nethttp.StatusBadRequest {
+					return nil
+				}
+
+				expectStatus(t, w, nethttp.StatusOK)
+
+				var res struct {
+					Data []Document[note] `json:"data"`
+				}
+
+				if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+					t.Fatal(err)
+				}
+
+				var texts []string
+
+				for _, d := range res.Data {
+					texts = append(texts, d.Data.Text)
+				}
+
+				return texts
+			}
+
+			if texts := list("X-Tenant-ID", "acme"); len(texts) != 1 || texts[0] != "acme" {
+				t.Fatalf("acme listed %v", texts)
+			}
+
+			type byID struct {
+				name    string
+				method  string
+				id      primitive.ObjectID
+				headers []string
+				status  int
+			}
+
+			tests := []byID{
+				{"own document", "GET", acme.ID, []string{"X-Tenant-ID", "acme"}, nethttp.StatusOK},
+				{"other tenant", "GET", globex.ID, []string{"X-Tenant-ID", "acme"}, nethttp.StatusNotFound},
+				{"shared document from tenant", "GET", shared.ID, []string{"X-Tenant-ID", "acme"}, nethttp.StatusNotFound},
+				{"update other tenant", "PATCH", globex.ID, []string{"X-Tenant-ID", "acme"}, nethttp.StatusNotFound},
+				{"delete other tenant", "DELETE", globex.ID, []string{"X-Tenant-ID", "acme"}, nethttp.StatusNotFound},
+				{"invalid tenant", "GET", acme.ID, []string{"X-Tenant-ID", "../admin"}, nethttp.StatusBadRequest},
+			}
+
+			if optional {
+				tests = append(tests,
+					byID{"tenant document without tenant", "GET", acme.ID, nil, nethttp.StatusNotFound},
+					byID{"delete tenant document without tenant", "DELETE", globex.ID, nil, nethttp.StatusNotFound},
+					byID{"shared document without tenant", "GET", shared.ID, nil, nethttp.StatusOK},
+				)
+			} else {
+				tests = append(tests, byID{"without tenant", "GET", acme.ID, nil, nethttp.StatusBadRequest})
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					var body any
+
+					if tt.method == "PATCH" {
+						body = map[string]any{"text": "changed"}
+					}
+
+					expectStatus(t, request(h, tt.method, "/notes/"+tt.id.Hex(), body, tt.headers...), tt.status)
+				})
+			}
+
+			texts := list()
+
+			if optional && (len(texts) != 1 || texts[0] != "shared") {
+				t.Fatalf("request without tenant listed %v", texts)
+			}
+
+			if !optional && texts != nil {
+				t.Fatalf("request without tenant was not rejected, listed %v", texts)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/alexsobiek/scaffold/auth"
+	"github.com/alexsobiek/scaffold/http"
+	"github.com/gin-gonic/gin"
+)
+
+// ContextKey is the gin key under which the request tenant is stored.
+const ContextKey = "tenant"
+
+type tenantKey struct{}
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Resolver determines the tenant a request belongs to. An empty string means the request does
+// not identify a tenant.
+type Resolver func(*gin.Context) (string, error)
+
+// Header resolves the tenant from a request header.
+func Header(name string) Resolver {
+	return func(c *gin.Context) (string, error) {
+		return c.GetHeader(name), nil
+	}
+}
+
+// Subdomain resolves the tenant from the host label preceding base, e.g. "acme" for
+// "acme.api.example.com" with a base of "api.example.com".
+func Subdomain(base string) Resolver {
+	suffix := "." + strings.ToLower(strings.TrimPrefix(base, "."))
+
+	return func(c *gin.Context) (string, error) {
+		host := c.Request.Host
+
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		host = strings.ToLower(host)
+
+		if !strings.HasSuffix(host, suffix) {
+			return "", nil
+		}
+
+		return strings.TrimSuffix(host, suffix), nil
+	}
+}
+
+// Claim resolves the tenant from a string claim of the authenticated principal.
+func Claim(name string) Resolver {
+	return func(c *gin.Context) (string, error) {
+		p, ok := auth.FromContext(c)
+
+		if !ok {
+			return "", nil
+		}
+
+		switch v := p.Claims[name].(type) {
+		case nil:
+			return "", nil
+		case string:
+			return v, nil
+		default:
+			return "", fmt.Errorf("claim %s is not a string", name)
+		}
+	}
+}
+
+// With returns a copy of ctx scoped to the given tenant.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// From returns the tenant ctx is scoped to, if any.
+func From(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+
+	if id, ok := ctx.Value(tenantKey{}).(string); ok && id != "" {
+		return id, true
+	}
+
+	if id, ok := ctx.Value(ContextKey).(string); ok && id != "" {
+		return id, true
+	}
+
+	return "", false
+}
+
+// Valid reports whether id may be used as a tenant identifier. Identifiers are restricted so
+// they are safe to embed in database names.
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// Middleware resolves the tenant of each request and scopes the request context to it. Requests
+// which do not identify a tenant are rejected unless optional is set.
+func Middleware(resolve Resolver, optional bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := resolve(c)
+
+		if err != nil {
+			http.BadRequest(c, err)
+			c.Abort()
+			return
+		}
+
+		if id == "" {
+			if !optional {
+				http.BadRequest(c, errors.New("tenant required"))
+				c.Abort()
+				return
+			}
+
+			c.Next()
+			return
+		}
+
+		if !Valid(id) {
+			http.BadRequest(c, errors.New("invalid tenant"))
+			c.Abort()
+			return
+		}
+
+		c.Set(ContextKey, id)
+		c.Request = c.Request.WithContext(With(c.Request.Context(), id))
+
+		http.AddLogAttrs(c, slog.String("tenant", id))
+
+		c.Next()
+	}
+}
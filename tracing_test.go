@@ -0,0 +1,80 @@
+package scaffold
+
+import (
+	"context"
+	nethttp "net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	audit := NewCollection(CollectionOpts[note]{Name: "Audit", Slug: "audit"})
+	notes := NewCollection(CollectionOpts[note]{
+		Name: "Notes",
+		Slug: "notes",
+		// Mongo operations made by hooks are children of the hook span
+		Access: func(ctx context.Context, id primitive.ObjectID) error {
+			_, err := audit.Insert(ctx, note{Text: "read " + id.Hex()})
+			return err
+		},
+	})
+
+	_, h := testScaffold(t, ScaffoldOpts{
+		Collections: []Collection{notes, audit},
+		Tracing:     &TracingOpts{TracerProvider: provider},
+	})
+
+	doc, err := notes.Insert(context.Background(), note{Text: "hello"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exporter.Reset()
+
+	expectStatus(t, request(h, "GET", "/notes/"+doc.ID.Hex(), nil), nethttp.StatusOK)
+
+	spans := map[string]tracetest.SpanStub{}
+
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+
+	parent := map[string]string{
+		"GET /notes/:id":       "",
+		"scaffold.hook.access": "GET /notes/:id",
+		"mongo.insert_one":     "scaffold.hook.access",
+		"mongo.find_one":       "GET /notes/:id",
+		"scaffold.hook.read":   "GET /notes/:id",
+	}
+
+	for name, parentName := range parent {
+		span, ok := spans[name]
+
+		if !ok {
+			t.Fatalf("no %s span", name)
+		}
+
+		if parentName == "" {
+			if span.Parent.IsValid() {
+				t.Fatalf("%s has a parent", name)
+			}
+
+			continue
+		}
+
+		if span.Parent.SpanID() != spans[parentName].SpanContext.SpanID() {
+			t.Fatalf("%s is not a child of %s", name, parentName)
+		}
+
+		if span.SpanContext.TraceID() != spans[parentName].SpanContext.TraceID() {
+			t.Fatalf("%s is not in the trace of %s", name, parentName)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package scaffold
+
+import (
+	"io"
+	"reflect"
+
+	"github.com/alexsobiek/scaffold/typescript"
+)
+
+// TypeScript writes TypeScript interfaces for the collections' types and a fetch based client
+// for their routes. It can be called before Start, e.g. from a go:generate program.
+func (s *Scaffold) TypeScript(w io.Writer) error {
+	collections := make([]typescript.Collection, len(s.opts.Collections))
+
+	for i, c := range s.opts.Collections {
+		collections[i] = typescript.Collection{Name: c.Name(), Slug: c.Slug(), Type: c.dataType()}
+	}
+
+	return typescript.Generate(w, collections)
+}
+
+func (c *C[T]) dataType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
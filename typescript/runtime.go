@@ -0,0 +1,133 @@
+package typescript
+
+// header declares the envelope types and the client shared by every collection.
+const header = `// Code generated by scaffold. DO NOT EDIT.
+
+export interface Document<T> {
+  id: string;
+  created: string;
+  last_updated: string;
+  document: T;
+}
+
+export interface Page<T> {
+  data: Document<T>[];
+  count: number;
+  page: number;
+}
+
+export type FilterOperator = "eq" | "ne" | "gt" | "gte" | "lt" | "lte" | "in" | "nin";
+
+export interface Filter {
+  field: string;
+  op?: FilterOperator;
+  value: string | number | boolean | (string | number)[];
+}
+
+export interface ListOptions {
+  filters?: Filter[];
+  // Fields to sort by, descending when prefixed with "-".
+  sort?: string[];
+  limit?: number;
+  page?: number;
+}
+
+export interface ClientOptions {
+  baseUrl: string;
+  headers?: Record<string, string>;
+  credentials?: RequestCredentials;
+  fetch?: typeof fetch;
+}
+
+export class ScaffoldError extends Error {
+  status: number;
+  requestId?: string;
+
+  constructor(status: number, message: string, requestId?: string) {
+    super(message);
+    this.name = "ScaffoldError";
+    this.status = status;
+    this.requestId = requestId;
+  }
+}
+
+export class CollectionClient<T> {
+  private options: ClientOptions;
+  private slug: string;
+
+  constructor(options: ClientOptions, slug: string) {
+    this.options = options;
+    this.slug = slug;
+  }
+
+  insert(data: T): Promise<Document<T>> {
+    return this.request<Document<T>>("POST", "/", data);
+  }
+
+  get(id: string): Promise<Document<T>> {
+    return this.request<Document<T>>("GET", "/" + id);
+  }
+
+  async list(options: ListOptions = {}): Promise<Page<T>> {
+    const query = new URLSearchParams();
+
+    for (const f of options.filters ?? []) {
+      const key = !f.op || f.op === "eq" ? f.field : f.field + "[" + f.op + "]";
+      query.append(key, Array.isArray(f.value) ? f.value.join(",") : String(f.value));
+    }
+
+    if (options.sort?.length) query.set("sort", options.sort.join(","));
+    if (options.limit) query.set("limit", String(options.limit));
+    if (options.page) query.set("page", String(options.page));
+
+    const qs = query.toString();
+    const path = "/" + (qs ? "?" + qs : "");
+
+    try {
+      return await this.send<Page<T>>("GET", path);
+    } catch (e) {
+      // The server reports an empty page as a bad request
+      if (e instanceof ScaffoldError && e.status === 400 && e.message === "no data") {
+        return { data: [], count: 0, page: options.page ?? 1 };
+      }
+
+      throw e;
+    }
+  }
+
+  // Sets fields of the document, keyed by their bson or Go field name.
+  patch(id: string, fields: Record<string, unknown>): Promise<Document<T>> {
+    return this.request<Document<T>>("PATCH", "/" + id, fields);
+  }
+
+  delete(id: string): Promise<Document<T>> {
+    return this.request<Document<T>>("DELETE", "/" + id);
+  }
+
+  private async request<R>(method: string, path: string, body?: unknown): Promise<R> {
+    const res = await this.send<{ data: R }>(method, path, body);
+    return res.data;
+  }
+
+  private async send<R>(method: string, path: string, body?: unknown): Promise<R> {
+    const headers: Record<string, string> = { Accept: "application/json", ...this.options.headers };
+
+    if (body !== undefined) headers["Content-Type"] = "application/json";
+
+    const res = await (this.options.fetch ?? fetch)(this.options.baseUrl.replace(/\/$/, "") + "/" + this.slug + path, {
+      method,
+      headers,
+      credentials: this.options.credentials,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+
+    const json = await res.json().catch(() => ({}));
+
+    if (!res.ok) {
+      throw new ScaffoldError(res.status, json.error ?? res.statusText, json.request_id);
+    }
+
+    return json as R;
+  }
+}
+`
@@ -0,0 +1,270 @@
+// Package typescript generates TypeScript types and a fetch based client for Scaffold collections.
+package typescript
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Collection describes a collection to generate types and a client for.
+type Collection struct {
+	Name string
+	Slug string
+	// Type is the Go type of the documents' data.
+	Type reflect.Type
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	dateTimeType  = reflect.TypeOf(primitive.DateTime(0))
+	objectIDType  = reflect.TypeOf(primitive.ObjectID{})
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textType      = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+var invalidName = regexp.MustCompile(`[^_$0-9A-Za-z]+`)
+
+// generator collects interface declarations for the struct types it encounters.
+type generator struct {
+	names map[reflect.Type]string
+	used  map[string]bool
+	decls []string
+}
+
+// Generate writes TypeScript interfaces for each collection's type, the Document envelope and a
+// client with methods for each collection.
+func Generate(w io.Writer, collections []Collection) error {
+	g := &generator{
+		names: map[reflect.Type]string{},
+		used:  map[string]bool{},
+	}
+
+	// Names declared by the runtime
+	for _, name := range []string{"Document", "Page", "Filter", "FilterOperator", "ListOptions", "ClientOptions", "ScaffoldError", "CollectionClient", "Client"} {
+		g.used[name] = true
+	}
+
+	types := make([]string, len(collections))
+
+	for i, c := range collections {
+		types[i] = g.typeOf(c.Type, "")
+	}
+
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprint(bw, header)
+
+	for _, decl := range g.decls {
+		fmt.Fprint(bw, "\n", decl)
+	}
+
+	fmt.Fprint(bw, "\nexport interface Client {\n")
+
+	for i, c := range collections {
+		fmt.Fprintf(bw, "  %s: CollectionClient<%s>;\n", propertyName(c.Slug), types[i])
+	}
+
+	fmt.Fprint(bw, "}\n\nexport function createClient(options: ClientOptions): Client {\n  return {\n")
+
+	for i, c := range collections {
+		fmt.Fprintf(bw, "    %s: new CollectionClient<%s>(options, %s),\n", propertyName(c.Slug), types[i], strconv.Quote(strings.Trim(c.Slug, "/")))
+	}
+
+	fmt.Fprint(bw, "  };\n}\n")
+
+	return bw.Flush()
+}
+
+// typeOf returns the TypeScript type of the JSON encoding of t, declaring interfaces for named
+// structs.
+func (g *generator) typeOf(t reflect.Type, indent string) string {
+	if t.Kind() == reflect.Pointer {
+		return g.typeOf(t.Elem(), indent) + " | null"
+	}
+
+	switch t {
+	case timeType, dateTimeType, objectIDType:
+		return "string"
+	}
+
+	if t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType) {
+		return "unknown"
+	}
+
+	if t.Implements(textType) || reflect.PointerTo(t).Implements(textType) {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+
+		return array(g.typeOf(t.Elem(), indent)) + " | null"
+	case reflect.Array:
+		return array(g.typeOf(t.Elem(), indent))
+	case reflect.Map:
+		return "Record<string, " + g.typeOf(t.Elem(), indent) + "> | null"
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t, indent)
+		}
+
+		if name, ok := g.names[t]; ok {
+			return name
+		}
+
+		name := g.name(t)
+		g.names[t] = name
+
+		// Reserve the position of the declaration before fields declare their own types
+		i := len(g.decls)
+		g.decls = append(g.decls, "")
+		g.decls[i] = "export interface " + name + " " + g.object(t, "") + "\n"
+
+		return name
+	default:
+		return "unknown"
+	}
+}
+
+func array(t string) string {
+	if strings.Contains(t, " ") {
+		return "(" + t + ")[]"
+	}
+
+	return t + "[]"
+}
+
+// name derives a unique interface name from a type name, stripping the package paths of type
+// arguments.
+func (g *generator) name(t reflect.Type) string {
+	name := t.Name()
+
+	if i := strings.Index(name, "["); i >= 0 {
+		args := strings.Split(strings.TrimSuffix(name[i+1:], "]"), ",")
+
+		for j, arg := range args {
+			args[j] = arg[strings.LastIndex(arg, ".")+1:]
+		}
+
+		name = name[:i] + "_" + strings.Join(args, "_")
+	}
+
+	name = invalidName.ReplaceAllString(name, "_")
+	unique := name
+
+	for i := 2; g.used[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+
+	g.used[unique] = true
+
+	return unique
+}
+
+// object returns an object literal type with the fields of t, following encoding/json rules.
+func (g *generator) object(t reflect.Type, indent string) string {
+	var b strings.Builder
+
+	b.WriteString("{\n")
+	g.fields(&b, t, indent+"  ")
+	b.WriteString(indent + "}")
+
+	return b.String()
+}
+
+func (g *generator) fields(b *strings.Builder, t reflect.Type, indent string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+
+		if name == "-" && len(tag) == 1 {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				g.fields(b, ft, indent)
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		optional := ""
+
+		for _, opt := range tag[1:] {
+			if opt == "omitempty" || opt == "omitzero" {
+				optional = "?"
+			}
+		}
+
+		fmt.Fprintf(b, "%s%s%s: %s;\n", indent, propertyKey(name), optional, g.typeOf(f.Type, indent))
+	}
+}
+
+var identifier = regexp.MustCompile(`^[_$A-Za-z][_$0-9A-Za-z]*$`)
+
+func propertyKey(name string) string {
+	if identifier.MatchString(name) {
+		return name
+	}
+
+	return strconv.Quote(name)
+}
+
+// propertyName converts a slug to a camel case property name, e.g. "some-struct" to "someStruct".
+func propertyName(slug string) string {
+	name := ""
+
+	for _, p := range invalidName.Split(slug, -1) {
+		if p == "" {
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(p[:1]) + p[1:]
+		} else {
+			name += strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+
+	if !identifier.MatchString(name) {
+		name = "_" + name
+	}
+
+	return name
+}
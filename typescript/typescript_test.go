@@ -0,0 +1,91 @@
+package typescript
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+type Audit struct {
+	CreatedBy string `json:"created_by"`
+}
+
+type Meta struct {
+	Views int `json:"views"`
+}
+
+type Author struct {
+	Name    string   `json:"name"`
+	Email   string   `json:"email,omitempty"`
+	Address *Address `json:"address"`
+}
+
+type Address struct {
+	City string `json:"city"`
+}
+
+// Page collides with the runtime's Page.
+type Page struct {
+	Title string `json:"title"`
+}
+
+type Post struct {
+	Audit
+	*Meta
+	ID        primitive.ObjectID    `json:"id"`
+	Title     string                `json:"title"`
+	Published time.Time             `json:"published"`
+	Edited    *time.Time            `json:"edited,omitempty"`
+	Updated   primitive.DateTime    `json:"updated"`
+	Author    Author                `json:"author"`
+	Tags      []string              `json:"tags"`
+	Counts    map[string]int        `json:"counts"`
+	Pages     []*Page               `json:"pages"`
+	Grid      [2]float64            `json:"grid"`
+	Body      []byte                `json:"body"`
+	Extra     json.RawMessage       `json:"extra"`
+	Location  struct{ Lat float64 } `json:"location"`
+	Secret    string                `json:"-"`
+	Dash      string                `json:"-,"`
+	Draft     bool
+	internal  string
+}
+
+func TestGenerate(t *testing.T) {
+	var b bytes.Buffer
+
+	err := Generate(&b, []Collection{
+		{Name: "Posts", Slug: "posts", Type: reflect.TypeOf(Post{})},
+		{Name: "Pages", Slug: "/blog-pages/", Type: reflect.TypeOf(Page{})},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := "testdata/client.ts"
+
+	if *update {
+		if err := os.WriteFile(golden, b.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(golden)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(b.Bytes(), expected) {
+		t.Fatalf("generated client differs from %s, run go test -update to accept it:\n%s", golden, b.String())
+	}
+}
@@ -0,0 +1,174 @@
+package scaffold
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Upcaster upgrades a stored document by one schema version. It receives the whole raw document,
+// including the envelope fields, and returns it in the shape of the next version.
+type Upcaster func(bson.M) (bson.M, error)
+
+// schemaVersion is the version documents are written with: one past the last upcaster.
+func (c *C[T]) schemaVersion() int {
+	return len(c.upcasters)
+}
+
+// decode decodes a stored document, upcasting it to the current schema version when it was
+// written with an older one.
+func (c *C[T]) decode(ctx context.Context, raw bson.Raw) (*Document[T], error) {
+	doc, m, err := c.unmarshal(raw)
+
+	if err != nil || m == nil || !c.writeBack {
+		return doc, err
+	}
+
+	// A failed write back only means the document is upcast again on its next read, the error is
+	// recorded by instrumentation
+	if ok, _ := c.replace(ctx, m, doc.stored); ok {
+		doc.stored = doc.SchemaVersion
+	}
+
+	return doc, nil
+}
+
+// unmarshal decodes a stored document like decode without writing it back, also returning the
+// upcast document, or nil when it was already current.
+func (c *C[T]) unmarshal(raw bson.Raw) (*Document[T], bson.M, error) {
+	// The driver cannot allocate Data itself when T embeds an inline struct
+	doc := Document[T]{Data: new(T)}
+
+	m, stored, err := c.upcast(raw)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if m == nil {
+		if err := bson.Unmarshal(raw, &doc); err != nil {
+			return nil, nil, err
+		}
+
+		doc.collection = c
+		doc.stored = doc.SchemaVersion
+
+		return &doc, nil, nil
+	}
+
+	b, err := bson.Marshal(m)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := bson.Unmarshal(b, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	doc.collection = c
+	doc.stored = stored
+
+	return &doc, m, nil
+}
+
+// upcast returns raw upgraded to the current schema version along with the version it was stored
+// at, or nil when it is already current.
+func (c *C[T]) upcast(raw bson.Raw) (bson.M, int, error) {
+	stored := 0
+
+	if v, ok := raw.Lookup("schema_version").AsInt64OK(); ok {
+		stored = int(v)
+	}
+
+	if stored >= c.schemaVersion() {
+		return nil, stored, nil
+	}
+
+	var m bson.M
+
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return nil, stored, err
+	}
+
+	for v := stored; v < c.schemaVersion(); v++ {
+		var err error
+		m, err = c.upcasters[v](m)
+
+		if err != nil {
+			return nil, stored, fmt.Errorf("upcasting document %v to schema version %d: %w", raw.Lookup("_id"), v+1, err)
+		}
+	}
+
+	m["schema_version"] = c.schemaVersion()
+
+	return m, stored, nil
+}
+
+// replace stores the upcast document m, provided it is still at schema version stored, reporting
+// whether it was.
+func (c *C[T]) replace(ctx context.Context, m bson.M, stored int) (bool, error) {
+	var version any = stored
+
+	if stored == 0 {
+		version = bson.M{"$in": bson.A{nil, 0}}
+	}
+
+	opCtx, done := c.op(ctx, "replace_one")
+	res, err := c.collection(ctx).ReplaceOne(opCtx, c.filter(ctx, bson.M{"_id": m["_id"], "schema_version": version}), m, &options.ReplaceOptions{Comment: comment(ctx)})
+	done(err)
+
+	if err != nil {
+		return false, err
+	}
+
+	return res.MatchedCount > 0, nil
+}
+
+// upgrade applies updates to a document stored at an older schema version, storing it whole at
+// the current version so fields which were not updated are upgraded too. The document is read
+// again rather than taken from memory, where read hooks may have changed it.
+func (d *Document[T]) upgrade(ctx context.Context, updates bson.M) error {
+	c := d.collection
+
+	opCtx, done := c.op(ctx, "find_one")
+	raw, err := c.collection(ctx).FindOne(opCtx, c.filter(ctx, bson.M{"_id": d.ID})).Raw()
+	done(err)
+
+	if err != nil {
+		return err
+	}
+
+	m, stored, err := c.upcast(raw)
+
+	if err != nil {
+		return err
+	}
+
+	// Upgraded since it was read, so only the updates need storing
+	if m == nil {
+		d.stored = stored
+		return d.set(ctx, updates)
+	}
+
+	for k, v := range updates {
+		m[k] = v
+	}
+
+	ok, err := c.replace(ctx, m, stored)
+
+	if err != nil {
+		return err
+	}
+
+	// Upgraded by another writer in the meantime
+	if !ok {
+		return d.set(ctx, updates)
+	}
+
+	d.stored = d.SchemaVersion
+
+	return nil
+}
@@ -0,0 +1,185 @@
+package scaffold
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexsobiek/scaffold/query"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type person struct {
+	First string `bson:"first" json:"first"`
+	Last  string `bson:"last" json:"last"`
+	Email string `bson:"email" json:"email"`
+}
+
+func TestUpcast(t *testing.T) {
+	// race, when set, runs once while the next document is upcast, e.g. to store the document
+	// concurrently
+	var race func()
+
+	upcasters := []Upcaster{
+		// Version 0 to 1: split name into first and last
+		func(doc bson.M) (bson.M, error) {
+			if r := race; r != nil {
+				race = nil
+				r()
+			}
+
+			name, _ := doc["name"].(string)
+			doc["first"], doc["last"], _ = strings.Cut(name, " ")
+			delete(doc, "name")
+
+			return doc, nil
+		},
+		// Version 1 to 2: rename mail to email
+		func(doc bson.M) (bson.M, error) {
+			if doc["mail"] == "invalid" {
+				return nil, errors.New("invalid mail")
+			}
+
+			doc["email"] = doc["mail"]
+			delete(doc, "mail")
+
+			return doc, nil
+		},
+	}
+
+	people := NewCollection(CollectionOpts[person]{Name: "People", Slug: "people", Upcasters: upcasters})
+	written := NewCollection(CollectionOpts[person]{Name: "Written", Slug: "written", Upcasters: upcasters, WriteBack: true})
+
+	testScaffold(t, ScaffoldOpts{Collections: []Collection{people, written}})
+
+	ctx := context.Background()
+
+	// store writes a document in the shape of version 0, which has no schema_version
+	store := func(t *testing.T, c *C[person], name string, mail string) primitive.ObjectID {
+		t.Helper()
+
+		id := primitive.NewObjectID()
+		now := primitive.NewDateTimeFromTime(time.Now())
+
+		_, err := c.mc.InsertOne(ctx, bson.M{"_id": id, "created": now, "last_updated": now, "name": name, "mail": mail})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return id
+	}
+
+	stored := func(t *testing.T, c *C[person], id primitive.ObjectID) bson.M {
+		t.Helper()
+
+		var m bson.M
+
+		if err := c.mc.FindOne(ctx, bson.M{"_id": id}).Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+
+		return m
+	}
+
+	upgraded := func(m bson.M) bool {
+		_, name := m["name"]
+		_, mail := m["mail"]
+
+		return !name && !mail && m["schema_version"] == int32(2)
+	}
+
+	t.Run("through several upcasters", func(t *testing.T) {
+		id := store(t, people, "Ada Lovelace", "ada@example.com")
+		doc, err := people.FindById(ctx, id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if *doc.Data != (person{"Ada", "Lovelace", "ada@example.com"}) || doc.SchemaVersion != 2 {
+			t.Fatalf("upcast to %+v at version %d", *doc.Data, doc.SchemaVersion)
+		}
+
+		// Without WriteBack the stored document is left alone
+		if m := stored(t, people, id); m["name"] != "Ada Lovelace" || m["schema_version"] != nil {
+			t.Fatalf("document was written back: %v", m)
+		}
+	})
+
+	t.Run("write back", func(t *testing.T) {
+		id := store(t, written, "Ada Lovelace", "ada@example.com")
+
+		if _, err := written.FindById(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+
+		if m := stored(t, written, id); !upgraded(m) || m["first"] != "Ada" || m["email"] != "ada@example.com" {
+			t.Fatalf("document was not written back: %v", m)
+		}
+	})
+
+	t.Run("update upgrades the whole document", func(t *testing.T) {
+		id := store(t, people, "Grace Hopper", "grace@example.com")
+		doc, err := people.FindById(ctx, id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := doc.Set(ctx, "email", "hopper@example.com"); err != nil {
+			t.Fatal(err)
+		}
+
+		if m := stored(t, people, id); !upgraded(m) || m["first"] != "Grace" || m["last"] != "Hopper" || m["email"] != "hopper@example.com" {
+			t.Fatalf("document was not upgraded: %v", m)
+		}
+	})
+
+	t.Run("update racing another writer", func(t *testing.T) {
+		id := store(t, people, "Alan Turing", "alan@example.com")
+		doc, err := people.FindById(ctx, id)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Another instance upgrades the document between the update reading and replacing it
+		race = func() {
+			now := primitive.NewDateTimeFromTime(time.Now())
+
+			_, err := people.mc.ReplaceOne(ctx, bson.M{"_id": id}, bson.M{
+				"_id": id, "created": now, "last_updated": now, "schema_version": 2,
+				"first": "Alan M.", "last": "Turing", "email": "alan@example.com",
+			})
+
+			if err != nil {
+				t.Error(err)
+			}
+		}
+
+		if err := doc.Set(ctx, "email", "turing@example.com"); err != nil {
+			t.Fatal(err)
+		}
+
+		if race != nil {
+			t.Fatal("the update did not upcast the stored document")
+		}
+
+		// The replacement matches nothing, so only the update is applied over the other write
+		if m := stored(t, people, id); !upgraded(m) || m["first"] != "Alan M." || m["email"] != "turing@example.com" {
+			t.Fatalf("stored %v", m)
+		}
+	})
+
+	t.Run("upcaster error", func(t *testing.T) {
+		id := store(t, people, "Ada Lovelace", "invalid")
+
+		if _, err := people.Find(ctx, query.ID(id)); err == nil || !strings.Contains(err.Error(), "invalid mail") {
+			t.Fatalf("expected the upcaster error, got %v", err)
+		}
+	})
+}